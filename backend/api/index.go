@@ -56,15 +56,15 @@ func init() {
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     cfg.CORS.AllowOrigins,
 		AllowMethods:     "GET,POST,PUT,DELETE,PATCH",
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, apikey, X-API-Key, X-Impersonate-User, X-Impersonate-Role, X-Impersonate-Org",
 		AllowCredentials: true,
 	}))
 
 	// Authentication middleware
-	app.Use(middleware.ClerkAuth(cfg.Auth))
+	app.Use(middleware.Authenticate(cfg.Auth, middleware.NewAuthenticators(cfg.Auth, database)))
 
 	// Set up routes
-	routes.Setup(app, database)
+	routes.Setup(app, database, cfg)
 }
 
 // Handler is the Vercel serverless function handler
@@ -73,7 +73,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "OPTIONS" {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, PATCH")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, apikey, X-API-Key, X-Impersonate-User, X-Impersonate-Role, X-Impersonate-Org")
 		w.WriteHeader(http.StatusOK)
 		return
 	}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackson/supabase-go/config"
+	"github.com/jackson/supabase-go/db"
+	"github.com/jackson/supabase-go/middleware"
+	"github.com/jackson/supabase-go/routes"
+)
+
+const usage = `Usage: admin <command> <subcommand> [flags]
+
+Commands:
+  policies export [-table name] [-format yaml|sql]
+  policies import [-table name] [-prune] [-dry-run] <file>
+  keys list
+  keys create -name name -roles role[,role] [-expires duration]
+  keys rotate [-overlap duration] [-expires duration] <id>
+  keys revoke <id>
+  token sign -sub user-id [-role role] [-org org-id] [-ttl duration]
+`
+
+func main() {
+	if len(os.Args) < 3 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Signing test tokens does not need the database
+	if os.Args[1] == "token" {
+		if err := runToken(cfg.Auth, os.Args[2], os.Args[3:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Initialize database
+	database, err := db.Connect(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	command, subcommand, args := os.Args[1], os.Args[2], os.Args[3:]
+
+	switch command {
+	case "policies":
+		err = runPolicies(ctx, database, subcommand, args)
+	case "keys":
+		err = runKeys(ctx, database, subcommand, args)
+	default:
+		err = fmt.Errorf("unknown command %q\n\n%s", command, usage)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runPolicies handles the policies subcommands
+func runPolicies(ctx context.Context, database *db.DB, subcommand string, args []string) error {
+	switch subcommand {
+	case "export":
+		flags := flag.NewFlagSet("policies export", flag.ExitOnError)
+		table := flags.String("table", "", "only export policies for this table")
+		format := flags.String("format", "yaml", "output format, yaml or sql")
+		flags.Parse(args)
+
+		if *format != "yaml" && *format != "sql" {
+			return fmt.Errorf("format must be either yaml or sql")
+		}
+
+		data, err := routes.ExportPolicies(ctx, database, *table, *format)
+		if err != nil {
+			return fmt.Errorf("failed to export policies: %w", err)
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+
+	case "import":
+		flags := flag.NewFlagSet("policies import", flag.ExitOnError)
+		table := flags.String("table", "", "only import and prune policies for this table")
+		prune := flags.Bool("prune", false, "drop stored policies missing from the file")
+		dryRun := flags.Bool("dry-run", false, "print the plan without applying it")
+		flags.Parse(args)
+
+		if flags.NArg() != 1 {
+			return fmt.Errorf("policies import requires exactly one file")
+		}
+
+		data, err := os.ReadFile(flags.Arg(0))
+		if err != nil {
+			return fmt.Errorf("failed to read policy file: %w", err)
+		}
+
+		doc, err := routes.LoadPolicyDocument(data)
+		if err != nil {
+			return fmt.Errorf("invalid policy document: %w", err)
+		}
+
+		plan, err := routes.PlanPolicyImport(ctx, database, doc, *table, *prune)
+		if err != nil {
+			return fmt.Errorf("invalid policy document: %w", err)
+		}
+
+		if !*dryRun {
+			if err := routes.ApplyPolicyPlan(ctx, database, plan, "admin-cli"); err != nil {
+				return fmt.Errorf("failed to apply policy plan: %w", err)
+			}
+		}
+
+		return printJSON(map[string]interface{}{
+			"dry_run": *dryRun,
+			"plan":    plan,
+		})
+	}
+
+	return fmt.Errorf("unknown policies subcommand %q\n\n%s", subcommand, usage)
+}
+
+// runKeys handles the keys subcommands. Keys created here do not need a
+// Clerk session, so they can bootstrap the first admin key.
+func runKeys(ctx context.Context, database *db.DB, subcommand string, args []string) error {
+	switch subcommand {
+	case "list":
+		keys, err := routes.ListAPIKeys(ctx, database)
+		if err != nil {
+			return fmt.Errorf("failed to list keys: %w", err)
+		}
+		return printJSON(keys)
+
+	case "create":
+		flags := flag.NewFlagSet("keys create", flag.ExitOnError)
+		name := flags.String("name", "", "name of the key")
+		roles := flags.String("roles", "", "comma separated roles, the first one is used for requests")
+		expires := flags.Duration("expires", 0, "expire the key after this duration, never when zero")
+		flags.Parse(args)
+
+		req := routes.APIKeyRequest{Name: *name, ExpiresAt: expiresAt(*expires)}
+		if *roles != "" {
+			req.Roles = strings.Split(*roles, ",")
+		}
+
+		issued, err := routes.IssueAPIKey(ctx, database, req, "admin-cli")
+		if err != nil {
+			return fmt.Errorf("failed to create key: %w", err)
+		}
+		fmt.Fprintln(os.Stderr, "Store this key now, it cannot be shown again.")
+		return printJSON(issued)
+
+	case "rotate":
+		flags := flag.NewFlagSet("keys rotate", flag.ExitOnError)
+		overlap := flags.Duration("overlap", 24*time.Hour, "how long the old key keeps working")
+		expires := flags.Duration("expires", 0, "expire the new key after this duration, never when zero")
+		flags.Parse(args)
+
+		if flags.NArg() != 1 {
+			return fmt.Errorf("keys rotate requires exactly one key ID")
+		}
+
+		issued, err := routes.RotateAPIKey(ctx, database, flags.Arg(0), *overlap, expiresAt(*expires), "admin-cli")
+		if err != nil {
+			return fmt.Errorf("failed to rotate key: %w", err)
+		}
+		fmt.Fprintln(os.Stderr, "Store this key now, it cannot be shown again.")
+		return printJSON(issued)
+
+	case "revoke":
+		if len(args) != 1 {
+			return fmt.Errorf("keys revoke requires exactly one key ID")
+		}
+		if err := routes.RevokeAPIKey(ctx, database, args[0]); err != nil {
+			return fmt.Errorf("failed to revoke key: %w", err)
+		}
+		fmt.Printf("Revoked API key %s\n", args[0])
+		return nil
+	}
+
+	return fmt.Errorf("unknown keys subcommand %q\n\n%s", subcommand, usage)
+}
+
+// runToken handles the token subcommands
+func runToken(cfg config.AuthConfig, subcommand string, args []string) error {
+	if subcommand != "sign" {
+		return fmt.Errorf("unknown token subcommand %q\n\n%s", subcommand, usage)
+	}
+
+	flags := flag.NewFlagSet("token sign", flag.ExitOnError)
+	sub := flags.String("sub", "", "user ID of the token")
+	role := flags.String("role", "", "role claim of the token")
+	org := flags.String("org", "", "active organization of the token")
+	ttl := flags.Duration("ttl", time.Hour, "lifetime of the token")
+	flags.Parse(args)
+
+	if cfg.TestSecret == "" {
+		return fmt.Errorf("AUTH_TEST_SECRET must be set to sign test tokens")
+	}
+	if *sub == "" {
+		return fmt.Errorf("token sign requires -sub")
+	}
+
+	claims := map[string]interface{}{cfg.JWTUserIDClaim: *sub}
+	if *role != "" {
+		claims[cfg.JWTRoleClaim] = *role
+	}
+	if *org != "" {
+		claims["org_id"] = *org
+	}
+
+	token, err := middleware.SignTestToken(cfg.TestSecret, claims, *ttl)
+	if err != nil {
+		return fmt.Errorf("failed to sign token: %w", err)
+	}
+	fmt.Println(token)
+	return nil
+}
+
+// expiresAt converts a lifetime flag to an expiry time, nil for no expiry
+func expiresAt(lifetime time.Duration) *time.Time {
+	if lifetime <= 0 {
+		return nil
+	}
+	t := time.Now().Add(lifetime)
+	return &t
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
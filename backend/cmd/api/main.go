@@ -49,15 +49,15 @@ func main() {
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     cfg.CORS.AllowOrigins,
 		AllowMethods:     "GET,POST,PUT,DELETE,PATCH",
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, apikey, X-API-Key, X-Impersonate-User, X-Impersonate-Role, X-Impersonate-Org",
 		AllowCredentials: true,
 	}))
 
 	// Authentication middleware
-	app.Use(middleware.ClerkAuth(cfg.Auth))
+	app.Use(middleware.Authenticate(cfg.Auth, middleware.NewAuthenticators(cfg.Auth, database)))
 
 	// Set up routes
-	routes.Setup(app, database)
+	routes.Setup(app, database, cfg)
 
 	// Start server
 	port := os.Getenv("PORT")
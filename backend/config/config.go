@@ -14,6 +14,7 @@ type Config struct {
 	Auth     AuthConfig
 	CORS     CORSConfig
 	Server   ServerConfig
+	Tenant   TenantConfig
 }
 
 // DatabaseConfig holds database connection parameters
@@ -33,9 +34,32 @@ type DatabaseConfig struct {
 
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
+	Providers           []string
+	PublicRoutes        []string
+	AnonEnabled         bool
+	AnonRoutes          []string
 	ClerkPublishableKey string
 	ClerkSecretKey      string
+	ClerkIssuer         string
+	ClerkParties        []string
 	JWTPublicKey        string
+	JWTSecret           string
+	JWKSURL             string
+	JWKSFile            string
+	JWTIssuer           string
+	JWTAudience         []string
+	JWTAlgorithms       []string
+	JWTUserIDClaim      string
+	JWTRoleClaim        string
+	JWTClockSkew        int // seconds
+	TestSecret          string
+}
+
+// TenantConfig holds the tenant-column mode settings
+type TenantConfig struct {
+	// Tables are scoped to the active organization through Column
+	Tables []string
+	Column string
 }
 
 // CORSConfig holds CORS settings
@@ -64,9 +88,24 @@ func Load() (*Config, error) {
 			SSLMode:  getEnv("DB_SSL_MODE", "disable"),
 		},
 		Auth: AuthConfig{
+			Providers:           getEnvAsList("AUTH_PROVIDERS"),
+			PublicRoutes:        getEnvAsList("AUTH_PUBLIC_ROUTES"),
+			AnonEnabled:         getEnvAsBool("AUTH_ANON_ENABLED", false),
+			AnonRoutes:          getEnvAsList("AUTH_ANON_ROUTES"),
 			ClerkPublishableKey: getEnv("CLERK_PUBLISHABLE_KEY", ""),
 			ClerkSecretKey:      getEnv("CLERK_SECRET_KEY", ""),
+			ClerkIssuer:         getEnv("CLERK_ISSUER", getEnv("JWT_ISSUER", "")),
+			ClerkParties:        getEnvAsList("CLERK_AUTHORIZED_PARTIES"),
 			JWTPublicKey:        getEnv("JWT_PUBLIC_KEY", ""),
+			JWTSecret:           getEnv("JWT_SECRET", ""),
+			JWKSURL:             getEnv("JWT_JWKS_URL", ""),
+			JWKSFile:            getEnv("JWT_JWKS_FILE", ""),
+			JWTIssuer:           getEnv("JWT_ISSUER", ""),
+			JWTAudience:         getEnvAsList("JWT_AUDIENCE"),
+			JWTAlgorithms:       getEnvAsList("JWT_ALGORITHMS"),
+			JWTUserIDClaim:      getEnv("JWT_USER_ID_CLAIM", "sub"),
+			JWTRoleClaim:        getEnv("JWT_ROLE_CLAIM", "role"),
+			TestSecret:          getEnv("AUTH_TEST_SECRET", ""),
 		},
 		CORS: CORSConfig{
 			AllowOrigins: getEnv("CORS_ALLOW_ORIGINS", "*"),
@@ -74,6 +113,10 @@ func Load() (*Config, error) {
 		Server: ServerConfig{
 			Port: getEnv("PORT", "8080"),
 		},
+		Tenant: TenantConfig{
+			Tables: getEnvAsList("TENANT_TABLES"),
+			Column: getEnv("TENANT_COLUMN", "org_id"),
+		},
 	}
 
 	// Parse database pool settings
@@ -81,6 +124,19 @@ func Load() (*Config, error) {
 	config.Database.Pool.MaxIdle = getEnvAsInt("DB_MAX_IDLE_CONNECTIONS", 5)
 	config.Database.Pool.MaxLifetime = getEnvAsInt("DB_MAX_CONNECTION_LIFETIME", 30)
 
+	// Parse token verification settings
+	config.Auth.JWTClockSkew = getEnvAsInt("JWT_CLOCK_SKEW", 5)
+
+	// Routes reachable without credentials
+	if len(config.Auth.PublicRoutes) == 0 {
+		config.Auth.PublicRoutes = []string{"/api/health", "/api/status"}
+	}
+	if len(config.Auth.AnonRoutes) == 0 {
+		// Only rows, where RLS decides what anon sees; table listings and
+		// columns would expose the schema
+		config.Auth.AnonRoutes = []string{"/api/tables/*/rows/*"}
+	}
+
 	return config, nil
 }
 
@@ -108,6 +164,28 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return value
 }
 
+// Helper function to get an environment variable as a boolean
+func getEnvAsBool(key string, defaultValue bool) bool {
+	switch strings.ToLower(getEnv(key, "")) {
+	case "1", "true", "yes", "on":
+		return true
+	case "0", "false", "no", "off":
+		return false
+	}
+	return defaultValue
+}
+
+// Helper function to get a comma separated environment variable as a list
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 // Helper function to parse an integer
 func parseInt(valueStr string) (int, error) {
 	var value int
@@ -16,6 +16,13 @@ type DB struct {
 	config config.DatabaseConfig
 }
 
+// Querier is the read interface shared by DB and pgx.Tx, so helpers can run
+// either directly on the pool or inside a transaction
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
 // Connect establishes a connection to the PostgreSQL database
 func Connect(cfg config.DatabaseConfig) (*DB, error) {
 	// Construct connection string
@@ -0,0 +1,34 @@
+-- Create the database roles API requests run as
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'anon') THEN
+        CREATE ROLE anon NOLOGIN;
+    END IF;
+
+    IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'authenticated') THEN
+        CREATE ROLE authenticated NOLOGIN;
+    END IF;
+END
+$$;
+
+-- Allow the connection owner to switch into the request roles with SET ROLE
+DO $$
+BEGIN
+    EXECUTE format('GRANT anon, authenticated TO %I', current_user);
+END
+$$;
+
+-- Grant table access to the request roles; row-level security policies
+-- decide which rows each role can actually see
+GRANT USAGE ON SCHEMA public TO anon, authenticated;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO anon, authenticated;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO anon, authenticated;
+
+ALTER DEFAULT PRIVILEGES IN SCHEMA public
+    GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO anon, authenticated;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public
+    GRANT USAGE, SELECT ON SEQUENCES TO anon, authenticated;
+
+-- Internal tables are managed by the API itself and must not be reachable
+-- through the request roles
+REVOKE ALL ON schema_migrations, rls_policies, api_keys, audit_logs, user_roles FROM anon, authenticated;
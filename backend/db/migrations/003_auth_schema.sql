@@ -0,0 +1,27 @@
+-- Create the auth schema with helpers for use inside RLS policies
+CREATE SCHEMA IF NOT EXISTS auth;
+
+GRANT USAGE ON SCHEMA auth TO anon, authenticated;
+
+-- Full claims of the current request, or NULL when there are none
+CREATE OR REPLACE FUNCTION auth.jwt()
+RETURNS JSONB AS $$
+    SELECT NULLIF(current_setting('request.jwt.claims', true), '')::jsonb;
+$$ LANGUAGE sql STABLE;
+
+-- Clerk user ID of the current request, or NULL for anonymous requests
+CREATE OR REPLACE FUNCTION auth.uid()
+RETURNS TEXT AS $$
+    SELECT COALESCE(
+        NULLIF(current_setting('request.user_id', true), ''),
+        auth.jwt() ->> 'sub'
+    );
+$$ LANGUAGE sql STABLE;
+
+-- Database role of the current request, 'anon' when there is no user
+CREATE OR REPLACE FUNCTION auth.role()
+RETURNS TEXT AS $$
+    SELECT COALESCE(auth.jwt() ->> 'role', 'anon');
+$$ LANGUAGE sql STABLE;
+
+GRANT EXECUTE ON FUNCTION auth.jwt(), auth.uid(), auth.role() TO anon, authenticated;
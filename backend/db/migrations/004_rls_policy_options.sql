@@ -0,0 +1,7 @@
+-- Add WITH CHECK expressions and permissive/restrictive mode to RLS policies
+ALTER TABLE rls_policies
+    ADD COLUMN IF NOT EXISTS check_definition TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS permissive BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- INSERT policies only take a WITH CHECK expression, so USING may be empty
+ALTER TABLE rls_policies ALTER COLUMN definition SET DEFAULT '';
@@ -0,0 +1,35 @@
+-- Store every change to an RLS policy as a numbered revision
+CREATE TABLE IF NOT EXISTS rls_policy_versions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    policy_id UUID NOT NULL, -- no foreign key, history outlives deleted policies
+    version INTEGER NOT NULL,
+    operation TEXT NOT NULL CHECK (operation IN ('create', 'update', 'delete', 'rollback')),
+    name TEXT NOT NULL,
+    table_name TEXT NOT NULL,
+    action TEXT NOT NULL,
+    roles TEXT NOT NULL, -- JSON array of role names
+    definition TEXT NOT NULL DEFAULT '',
+    check_definition TEXT NOT NULL DEFAULT '',
+    permissive BOOLEAN NOT NULL DEFAULT TRUE,
+    description TEXT,
+    changed_by TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (policy_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rls_policy_versions_policy_id ON rls_policy_versions (policy_id);
+
+-- Existing policies start their history at version 1, so they can be
+-- rolled back to their state before the first tracked change
+INSERT INTO rls_policy_versions (
+    policy_id, version, operation, name, table_name, action, roles,
+    definition, check_definition, permissive, description, created_at
+)
+SELECT
+    p.id, 1, 'create', p.name, p.table_name, p.action, p.roles,
+    p.definition, p.check_definition, p.permissive, p.description, p.updated_at
+FROM rls_policies p
+WHERE NOT EXISTS (SELECT 1 FROM rls_policy_versions v WHERE v.policy_id = p.id);
+
+-- Internal table, not reachable through the request roles
+REVOKE ALL ON rls_policy_versions FROM anon, authenticated;
@@ -0,0 +1,10 @@
+-- Keys are shown once when issued, only their hash is stored
+ALTER TABLE api_keys ALTER COLUMN key DROP NOT NULL;
+
+-- Replace the plaintext of existing keys with their hash
+UPDATE api_keys
+SET hashed_key = encode(digest(key, 'sha256'), 'hex'), key = NULL
+WHERE key IS NOT NULL;
+
+-- A rotated key points at the key that replaces it
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS replaced_by UUID REFERENCES api_keys(id);
@@ -0,0 +1,5 @@
+-- Mark the role a user's requests run as when they have several
+ALTER TABLE user_roles ADD COLUMN IF NOT EXISTS is_primary BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- At most one primary role per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_roles_primary ON user_roles (user_id) WHERE is_primary;
@@ -0,0 +1,12 @@
+-- Impersonated requests are audited without a table or record
+ALTER TABLE audit_logs ALTER COLUMN table_name DROP NOT NULL;
+ALTER TABLE audit_logs ALTER COLUMN record_id DROP NOT NULL;
+ALTER TABLE audit_logs DROP CONSTRAINT IF EXISTS audit_logs_action_check;
+ALTER TABLE audit_logs ADD CONSTRAINT audit_logs_action_check
+    CHECK (action IN ('INSERT', 'UPDATE', 'DELETE', 'IMPERSONATE'));
+
+-- The principal that acted on behalf of user_id
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS actor_id TEXT;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS metadata JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_id ON audit_logs (actor_id) WHERE actor_id IS NOT NULL;
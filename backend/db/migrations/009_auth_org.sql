@@ -0,0 +1,16 @@
+-- Active organization of the current request, or NULL without one
+CREATE OR REPLACE FUNCTION auth.org_id()
+RETURNS TEXT AS $$
+    SELECT COALESCE(
+        NULLIF(current_setting('request.org_id', true), ''),
+        auth.jwt() ->> 'org_id'
+    );
+$$ LANGUAGE sql STABLE;
+
+-- The user's role in the active organization, e.g. 'org:admin'
+CREATE OR REPLACE FUNCTION auth.org_role()
+RETURNS TEXT AS $$
+    SELECT COALESCE(auth.jwt() ->> 'org_role', auth.jwt() -> 'o' ->> 'rol');
+$$ LANGUAGE sql STABLE;
+
+GRANT EXECUTE ON FUNCTION auth.org_id(), auth.org_role() TO anon, authenticated;
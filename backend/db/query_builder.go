@@ -6,7 +6,6 @@ import (
 	"strings"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackson/supabase-go/pkg/querybuilder"
 )
 
@@ -21,13 +20,13 @@ type QueryBuilder struct {
 // NewQueryBuilder creates a new QueryBuilder for the specified table
 func (db *DB) NewQueryBuilder(tableName string) *QueryBuilder {
 	return &QueryBuilder{
-		db:         db,
-		tableName:  tableName,
-		selectCols: []string{"*"},
+		db:        db,
+		tableName: tableName,
 	}
 }
 
-// Select specifies the columns to select
+// Select specifies the columns to select, replacing the select list of
+// the query parameters
 func (qb *QueryBuilder) Select(columns ...string) *QueryBuilder {
 	if len(columns) > 0 {
 		qb.selectCols = columns
@@ -42,35 +41,9 @@ func (qb *QueryBuilder) Join(joinClause string) *QueryBuilder {
 }
 
 // Find executes the query and scans the results into dest
-func (qb *QueryBuilder) Find(ctx context.Context, queryParams *querybuilder.QueryParams, dest interface{}) error {
-	// Build the base query
-	query := fmt.Sprintf("SELECT %s FROM \"%s\" t", strings.Join(qb.selectCols, ", "), qb.tableName)
-	var args []interface{}
-
-	// Add JOIN clause if specified
-	if qb.joinClause != "" {
-		query += " " + qb.joinClause
-	}
-
-	// Add WHERE clause
-	whereClause, whereArgs := queryParams.BuildWhereClause()
-	if whereClause != "" {
-		query += " " + whereClause
-		args = append(args, whereArgs...)
-	}
-
-	// Add ORDER BY clause
-	orderByClause := queryParams.BuildOrderByClause()
-	if orderByClause != "" {
-		query += " " + orderByClause
-	}
-
-	// Add pagination
-	paginationClause, paginationArgs := queryParams.BuildPaginationClause()
-	if paginationClause != "" {
-		query += " " + paginationClause
-		args = append(args, paginationArgs...)
-	}
+func (qb *QueryBuilder) Find(ctx context.Context, queryParams *querybuilder.Query, dest interface{}) error {
+	var args querybuilder.Args
+	query := qb.selectSQL(queryParams, &args) + " " + queryParams.Pagination()
 
 	// Execute the query
 	rows, err := qb.db.pool.Query(ctx, query, args...)
@@ -84,22 +57,9 @@ func (qb *QueryBuilder) Find(ctx context.Context, queryParams *querybuilder.Quer
 }
 
 // Count executes a COUNT query with the same filters
-func (qb *QueryBuilder) Count(ctx context.Context, queryParams *querybuilder.QueryParams) (int, error) {
-	// Build the base query
-	query := fmt.Sprintf("SELECT COUNT(*) FROM \"%s\" t", qb.tableName)
-	var args []interface{}
-
-	// Add JOIN clause if specified
-	if qb.joinClause != "" {
-		query += " " + qb.joinClause
-	}
-
-	// Add WHERE clause
-	whereClause, whereArgs := queryParams.BuildWhereClause()
-	if whereClause != "" {
-		query += " " + whereClause
-		args = append(args, whereArgs...)
-	}
+func (qb *QueryBuilder) Count(ctx context.Context, queryParams *querybuilder.Query) (int, error) {
+	var args querybuilder.Args
+	query := fmt.Sprintf("SELECT COUNT(*) %s", qb.fromSQL(queryParams, &args))
 
 	// Execute the query
 	var count int
@@ -112,34 +72,9 @@ func (qb *QueryBuilder) Count(ctx context.Context, queryParams *querybuilder.Que
 }
 
 // FindOne executes the query and scans the first result into dest
-func (qb *QueryBuilder) FindOne(ctx context.Context, queryParams *querybuilder.QueryParams, dest interface{}) error {
-	// Set limit to 1 for single result
-	queryParams.Limit = 1
-
-	// Build the base query
-	query := fmt.Sprintf("SELECT %s FROM \"%s\" t", strings.Join(qb.selectCols, ", "), qb.tableName)
-	var args []interface{}
-
-	// Add JOIN clause if specified
-	if qb.joinClause != "" {
-		query += " " + qb.joinClause
-	}
-
-	// Add WHERE clause
-	whereClause, whereArgs := queryParams.BuildWhereClause()
-	if whereClause != "" {
-		query += " " + whereClause
-		args = append(args, whereArgs...)
-	}
-
-	// Add ORDER BY clause
-	orderByClause := queryParams.BuildOrderByClause()
-	if orderByClause != "" {
-		query += " " + orderByClause
-	}
-
-	// Add LIMIT 1
-	query += " LIMIT 1"
+func (qb *QueryBuilder) FindOne(ctx context.Context, queryParams *querybuilder.Query, dest interface{}) error {
+	var args querybuilder.Args
+	query := qb.selectSQL(queryParams, &args) + " LIMIT 1"
 
 	// Execute the query
 	rows, err := qb.db.pool.Query(ctx, query, args...)
@@ -151,3 +86,29 @@ func (qb *QueryBuilder) FindOne(ctx context.Context, queryParams *querybuilder.Q
 	// Scan the result
 	return pgxscan.ScanOne(dest, rows)
 }
+
+// selectSQL renders the SELECT statement without pagination. The table is
+// aliased as t for use in joins.
+func (qb *QueryBuilder) selectSQL(queryParams *querybuilder.Query, args *querybuilder.Args) string {
+	columns := queryParams.Columns("t")
+	if len(qb.selectCols) > 0 {
+		columns = strings.Join(qb.selectCols, ", ")
+	}
+	query := fmt.Sprintf("SELECT %s %s", columns, qb.fromSQL(queryParams, args))
+	if orderBy := queryParams.OrderBy("t"); orderBy != "" {
+		query += " " + orderBy
+	}
+	return query
+}
+
+// fromSQL renders the FROM, JOIN and WHERE clauses
+func (qb *QueryBuilder) fromSQL(queryParams *querybuilder.Query, args *querybuilder.Args) string {
+	query := fmt.Sprintf("FROM %s t", pgx.Identifier{qb.tableName}.Sanitize())
+	if qb.joinClause != "" {
+		query += " " + qb.joinClause
+	}
+	if where := queryParams.Where("t", args); where != "" {
+		query += " WHERE " + where
+	}
+	return query
+}
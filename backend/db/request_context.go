@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Database roles used when a request has no more specific role
+const (
+	AnonRole          = "anon"
+	AuthenticatedRole = "authenticated"
+)
+
+// RequestClaims describes the identity a request runs as inside PostgreSQL
+type RequestClaims struct {
+	Role   string
+	UserID string
+	OrgID  string
+	Claims map[string]interface{}
+}
+
+// WithRequestClaims runs fn inside a transaction that has switched to the
+// caller's database role and exposes the caller's claims to SQL, so that
+// row-level security policies are enforced by PostgreSQL itself.
+// The transaction is committed if fn succeeds and rolled back otherwise.
+func (db *DB) WithRequestClaims(ctx context.Context, rc RequestClaims, fn func(tx pgx.Tx) error) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := SetRequestClaims(ctx, tx, rc); err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// SetRequestClaims switches the current transaction to the request role and
+// sets the request.jwt.claims and request.user_id settings for its duration
+func SetRequestClaims(ctx context.Context, tx pgx.Tx, rc RequestClaims) error {
+	role := rc.Role
+	if role == "" {
+		role = AnonRole
+	}
+
+	// Copy the claims so the caller's map is left untouched
+	claims := make(map[string]interface{}, len(rc.Claims)+2)
+	for k, v := range rc.Claims {
+		claims[k] = v
+	}
+	claims["role"] = role
+	if rc.UserID != "" {
+		claims["sub"] = rc.UserID
+	}
+	if rc.OrgID != "" {
+		claims["org_id"] = rc.OrgID
+	}
+
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return fmt.Errorf("failed to encode request claims: %w", err)
+	}
+
+	// set_config with is_local = true behaves like SET LOCAL
+	_, err = tx.Exec(ctx,
+		"SELECT set_config('request.jwt.claims', $1, true), set_config('request.user_id', $2, true), set_config('request.org_id', $3, true)",
+		string(claimsJSON), rc.UserID, rc.OrgID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set request claims: %w", err)
+	}
+
+	// SET ROLE does not accept parameters, so the role is quoted as an identifier
+	_, err = tx.Exec(ctx, "SET LOCAL ROLE "+pgx.Identifier{role}.Sanitize())
+	if err != nil {
+		return fmt.Errorf("failed to set role %s: %w", role, err)
+	}
+
+	return nil
+}
@@ -56,15 +56,15 @@ func init() {
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     cfg.CORS.AllowOrigins,
 		AllowMethods:     "GET,POST,PUT,DELETE,PATCH",
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, apikey, X-API-Key, X-Impersonate-User, X-Impersonate-Role, X-Impersonate-Org",
 		AllowCredentials: true,
 	}))
 
 	// Authentication middleware
-	app.Use(middleware.ClerkAuth(cfg.Auth))
+	app.Use(middleware.Authenticate(cfg.Auth, middleware.NewAuthenticators(cfg.Auth, database)))
 
 	// Set up routes
-	routes.Setup(app, database)
+	routes.Setup(app, database, cfg)
 }
 
 // Handler is the Lambda handler for Netlify Functions
@@ -144,7 +144,7 @@ func httpResponseToAPIGateway(resp *http.Response) (events.APIGatewayProxyRespon
 
 	// Set CORS headers for all responses
 	headers["Access-Control-Allow-Origin"] = "*"
-	headers["Access-Control-Allow-Headers"] = "Content-Type, Authorization"
+	headers["Access-Control-Allow-Headers"] = "Content-Type, Authorization, apikey, X-API-Key, X-Impersonate-User, X-Impersonate-Role, X-Impersonate-Org"
 	headers["Access-Control-Allow-Methods"] = "GET, POST, PUT, DELETE, PATCH, OPTIONS"
 
 	return events.APIGatewayProxyResponse{
@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackson/supabase-go/db"
+)
+
+// APIKeyPrefix starts every API key. Keys have the form
+// sbk_<prefix>_<secret>, where prefix identifies the api_keys row.
+const APIKeyPrefix = "sbk_"
+
+// apiKeyTouchInterval limits how often last_used_at is written for a key
+const apiKeyTouchInterval = time.Minute
+
+// API key errors returned by Verify
+var (
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	ErrAPIKeyExpired = errors.New("API key has expired")
+	ErrAPIKeyRevoked = errors.New("API key has been revoked")
+)
+
+// APIKey is an authenticated row of the api_keys table
+type APIKey struct {
+	ID        string
+	Name      string
+	Prefix    string
+	Roles     []string
+	ExpiresAt *time.Time
+	CreatedBy string
+}
+
+// APIKeyAuth verifies API keys against the api_keys table
+type APIKeyAuth struct {
+	db *db.DB
+}
+
+// NewAPIKeyAuth creates an API key verifier
+func NewAPIKeyAuth(database *db.DB) *APIKeyAuth {
+	return &APIKeyAuth{db: database}
+}
+
+// Verify looks up a raw API key by its prefix and verifies its hash
+func (a *APIKeyAuth) Verify(ctx context.Context, rawKey string) (*APIKey, error) {
+	prefix, ok := ParseAPIKeyPrefix(rawKey)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	query := `
+		SELECT id, name, prefix, hashed_key, roles, expires_at, COALESCE(created_by, ''), revoked
+		FROM api_keys
+		WHERE prefix = $1
+	`
+
+	var key APIKey
+	var hashedKey, rolesJson string
+	var revoked bool
+	err := a.db.QueryRow(ctx, query, prefix).Scan(
+		&key.ID,
+		&key.Name,
+		&key.Prefix,
+		&hashedKey,
+		&rolesJson,
+		&key.ExpiresAt,
+		&key.CreatedBy,
+		&revoked,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	// Compare digests so the comparison time does not depend on the key
+	if subtle.ConstantTimeCompare([]byte(HashAPIKey(rawKey)), []byte(hashedKey)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	if revoked {
+		return nil, ErrAPIKeyRevoked
+	}
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(time.Now()) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	if err := json.Unmarshal([]byte(rolesJson), &key.Roles); err != nil {
+		return nil, err
+	}
+
+	go a.touch(key.ID)
+
+	return &key, nil
+}
+
+// touch updates last_used_at outside the request, at most once per
+// apiKeyTouchInterval
+func (a *APIKeyAuth) touch(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE api_keys
+		SET last_used_at = NOW()
+		WHERE id = $1
+		AND (last_used_at IS NULL OR last_used_at < NOW() - $2::interval)
+	`
+
+	if _, err := a.db.Exec(ctx, query, id, apiKeyTouchInterval.String()); err != nil {
+		log.Printf("Failed to update last_used_at for API key %s: %v", id, err)
+	}
+}
+
+// ParseAPIKeyPrefix returns the lookup prefix of a raw API key
+func ParseAPIKeyPrefix(rawKey string) (string, bool) {
+	if !strings.HasPrefix(rawKey, APIKeyPrefix) {
+		return "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(rawKey, APIKeyPrefix), "_", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", false
+	}
+	return parts[0], true
+}
+
+// GenerateAPIKey returns a new random key along with its lookup prefix
+func GenerateAPIKey() (rawKey, prefix string, err error) {
+	buf := make([]byte, 36)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	prefix = hex.EncodeToString(buf[:4])
+	rawKey = APIKeyPrefix + prefix + "_" + hex.EncodeToString(buf[4:])
+	return rawKey, prefix, nil
+}
+
+// HashAPIKey returns the hex encoded SHA-256 digest stored for a key
+func HashAPIKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// Name implements Authenticator
+func (a *APIKeyAuth) Name() string { return "api_key" }
+
+// Authenticate implements Authenticator for keys sent in the apikey or
+// X-API-Key header
+func (a *APIKeyAuth) Authenticate(c *fiber.Ctx) (*Principal, error) {
+	rawKey := c.Get("apikey")
+	if rawKey == "" {
+		rawKey = c.Get("X-API-Key")
+	}
+	if rawKey == "" {
+		return nil, ErrNoCredentials
+	}
+
+	key, err := a.Verify(context.Background(), rawKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// The first role is the one requests run as
+	role := "user"
+	if len(key.Roles) > 0 {
+		role = key.Roles[0]
+	}
+
+	userID := "apikey:" + key.ID
+	c.Locals("apiKey", key)
+
+	return &Principal{
+		UserID:    userID,
+		Role:      role,
+		Roles:     key.Roles,
+		Method:    a.Name(),
+		ExpiresAt: key.ExpiresAt,
+		Claims: map[string]interface{}{
+			"sub":          userID,
+			"api_key_id":   key.ID,
+			"api_key_name": key.Name,
+			"roles":        key.Roles,
+		},
+	}, nil
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+	"testing"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	rawKey, prefix, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateAPIKey: %v", err)
+	}
+	if !strings.HasPrefix(rawKey, APIKeyPrefix+prefix+"_") {
+		t.Errorf("key %q does not start with %q", rawKey, APIKeyPrefix+prefix+"_")
+	}
+
+	parsed, ok := ParseAPIKeyPrefix(rawKey)
+	if !ok || parsed != prefix {
+		t.Errorf("ParseAPIKeyPrefix() = %q, %v, want %q, true", parsed, ok, prefix)
+	}
+
+	other, _, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateAPIKey: %v", err)
+	}
+	if other == rawKey {
+		t.Errorf("GenerateAPIKey returned the same key twice")
+	}
+}
+
+func TestParseAPIKeyPrefix(t *testing.T) {
+	tests := []struct {
+		name   string
+		rawKey string
+		want   string
+		wantOK bool
+	}{
+		{"valid", "sbk_abcd1234_secret", "abcd1234", true},
+		{"underscore in secret", "sbk_abcd1234_sec_ret", "abcd1234", true},
+		{"wrong prefix", "sk_abcd1234_secret", "", false},
+		{"no secret", "sbk_abcd1234", "", false},
+		{"empty secret", "sbk_abcd1234_", "", false},
+		{"empty prefix", "sbk__secret", "", false},
+		{"empty", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseAPIKeyPrefix(tt.rawKey)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ParseAPIKeyPrefix(%q) = %q, %v, want %q, %v", tt.rawKey, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestHashAPIKey(t *testing.T) {
+	rawKey, _, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateAPIKey: %v", err)
+	}
+	stored := HashAPIKey(rawKey)
+
+	if stored == rawKey || strings.Contains(stored, rawKey) {
+		t.Fatalf("HashAPIKey stores the raw key")
+	}
+	if len(stored) != 64 {
+		t.Errorf("HashAPIKey() length = %d, want 64 hex characters", len(stored))
+	}
+
+	// Verify compares the digest of the presented key with the stored one
+	tests := []struct {
+		name      string
+		presented string
+		want      bool
+	}{
+		{"same key", rawKey, true},
+		{"changed secret", rawKey[:len(rawKey)-1] + "x", false},
+		{"truncated", rawKey[:len(rawKey)-1], false},
+		{"stored digest", stored, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := subtle.ConstantTimeCompare([]byte(HashAPIKey(tt.presented)), []byte(stored)) == 1
+			if got != tt.want {
+				t.Errorf("compare(%q) = %v, want %v", tt.presented, got, tt.want)
+			}
+		})
+	}
+}
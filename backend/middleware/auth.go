@@ -1,134 +1,252 @@
 package middleware
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"strings"
+	"log"
+	"sync"
+	"time"
 
 	"github.com/clerkinc/clerk-sdk-go"
 	"github.com/gofiber/fiber/v2"
 	"github.com/jackson/supabase-go/config"
 )
 
-// ClerkAuth returns a middleware that handles authentication using Clerk
-func ClerkAuth(cfg config.AuthConfig) fiber.Handler {
-	// Initialize Clerk client
+// metadataRolesTTL is how long roles read from a user's Clerk public
+// metadata are reused before they are fetched again
+const metadataRolesTTL = 5 * time.Minute
+
+// ClerkAuthenticator authenticates Clerk session tokens. Tokens are verified
+// locally against Clerk's JWKS, so no request to Clerk is made unless a
+// handler asks for the user's profile, or the token carries no roles.
+type ClerkAuthenticator struct {
+	client   clerk.Client
+	verifier *JWTVerifier
+	parties  map[string]bool
+
+	mu    sync.Mutex
+	roles map[string]cachedRoles
+}
+
+// cachedRoles are the roles read from a user's public metadata
+type cachedRoles struct {
+	roles     []string
+	fetchedAt time.Time
+}
+
+// NewClerkAuthenticator creates the Clerk provider
+func NewClerkAuthenticator(cfg config.AuthConfig) (*ClerkAuthenticator, error) {
+	if cfg.ClerkSecretKey == "" {
+		return nil, errors.New("CLERK_SECRET_KEY is not configured")
+	}
+	if cfg.ClerkIssuer == "" {
+		return nil, errors.New("CLERK_ISSUER is not configured")
+	}
+
+	// The Clerk client is only needed for profile lookups
 	client, err := clerk.NewClient(cfg.ClerkSecretKey)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to initialize Clerk client: %v", err))
+		return nil, fmt.Errorf("failed to initialize Clerk client: %w", err)
 	}
 
-	return func(c *fiber.Ctx) error {
-		// Skip authentication for specific routes if needed
-		path := c.Path()
-		if isPublicRoute(path) {
-			return c.Next()
-		}
+	verifier, err := NewJWTVerifier(JWTVerifierConfig{
+		JWKSURL:     clerkJWKSURL,
+		BearerToken: cfg.ClerkSecretKey,
+		Issuer:      cfg.ClerkIssuer,
+		ClockSkew:   cfg.JWTClockSkew,
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		// Get authorization header
-		authHeader := c.Get("Authorization")
-		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Missing authorization header",
-			})
-		}
+	parties := make(map[string]bool, len(cfg.ClerkParties))
+	for _, party := range cfg.ClerkParties {
+		parties[party] = true
+	}
 
-		// Parse token from header
-		token := parseAuthHeader(authHeader)
-		if token == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid authorization header format",
-			})
-		}
+	return &ClerkAuthenticator{
+		client:   client,
+		verifier: verifier,
+		parties:  parties,
+		roles:    make(map[string]cachedRoles),
+	}, nil
+}
 
-		// Verify the JWT token
-		claims, err := client.VerifyToken(token)
-		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid token",
-			})
-		}
+// Name implements Authenticator
+func (a *ClerkAuthenticator) Name() string { return "clerk" }
 
-		// Get session and user information
-		sessions, err := client.Sessions().GetAll(&clerk.GetSessionsParams{
-			ClientToken: token,
-		})
-		if err != nil || len(sessions) == 0 {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Session not found",
-			})
-		}
+// Authenticate implements Authenticator
+func (a *ClerkAuthenticator) Authenticate(c *fiber.Ctx) (*Principal, error) {
+	token, err := bearerToken(c)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := a.verifier.Verify(context.Background(), token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
 
-		// Get the user
-		user, err := client.Users().Read(claims.GetSubject())
-		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "User not found",
-			})
+	// Clerk sets azp to the origin the session token was issued for
+	if len(a.parties) > 0 {
+		if azp, _ := claims.Raw["azp"].(string); !a.parties[azp] {
+			return nil, ErrInvalidToken
 		}
+	}
 
-		// Set user and session info in context for use in route handlers
-		c.Locals("user", user)
-		c.Locals("session", sessions[0])
-		c.Locals("userId", user.ID)
-		c.Locals("userRole", getUserRole(user))
+	c.Locals("userLoader", newUserLoader(a.client, claims.Subject))
 
-		return c.Next()
+	// Without a session token template the roles are only in the user's
+	// public metadata, where they were always read from
+	roles := rolesFromClaims(claims.Raw)
+	if len(roles) == 0 {
+		roles = a.metadataRoles(c, claims.Subject)
 	}
-}
 
-// parseAuthHeader extracts the token from the authorization header
-func parseAuthHeader(authHeader string) string {
-	parts := strings.Split(authHeader, " ")
-	if len(parts) != 2 || parts[0] != "Bearer" {
-		return ""
+	// The first role is the one requests run as
+	role := "user"
+	if len(roles) > 0 {
+		role = roles[0]
 	}
-	return parts[1]
+
+	orgID, orgRole := orgFromClaims(claims.Raw)
+
+	return &Principal{
+		UserID:    claims.Subject,
+		Role:      role,
+		Roles:     roles,
+		SessionID: claims.SessionID(),
+		OrgID:     orgID,
+		OrgRole:   orgRole,
+		Method:    a.Name(),
+		ExpiresAt: claims.ExpiresAt(),
+		Claims:    claims.Raw,
+	}, nil
 }
 
-// isPublicRoute checks if a route should be publicly accessible
-func isPublicRoute(path string) bool {
-	publicRoutes := []string{
-		"/api/health",
-		"/api/status",
-		"/api/schema",
+// metadataRoles returns the roles in the public metadata of the user's Clerk
+// profile, cached for metadataRolesTTL. Profiles that cannot be fetched give
+// no roles rather than failing the request.
+func (a *ClerkAuthenticator) metadataRoles(c *fiber.Ctx, userID string) []string {
+	a.mu.Lock()
+	cached, ok := a.roles[userID]
+	a.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < metadataRolesTTL {
+		return cached.roles
+	}
+
+	user, err := CurrentUser(c)
+	if err != nil {
+		log.Printf("Failed to read Clerk profile of %s for its roles: %v", userID, err)
+		return nil
 	}
 
-	for _, route := range publicRoutes {
-		if path == route {
-			return true
+	var metadata interface{} = user.PublicMetadata
+	public, _ := metadata.(map[string]interface{})
+	roles := rolesFromClaims(map[string]interface{}{"public_metadata": public})
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for id, entry := range a.roles {
+		if time.Since(entry.fetchedAt) >= metadataRolesTTL {
+			delete(a.roles, id)
 		}
 	}
+	a.roles[userID] = cachedRoles{roles: roles, fetchedAt: time.Now()}
+	return roles
+}
+
+// userLoader fetches the Clerk user of a request at most once
+type userLoader struct {
+	once   sync.Once
+	client clerk.Client
+	userID string
+	user   *clerk.User
+	err    error
+}
 
-	return false
+// newUserLoader returns a loader for the given user
+func newUserLoader(client clerk.Client, userID string) *userLoader {
+	return &userLoader{client: client, userID: userID}
 }
 
-// getUserRole extracts user role from Clerk user metadata
-func getUserRole(user *clerk.User) string {
-	// Default role
-	defaultRole := "user"
+// CurrentUser returns the Clerk profile of the authenticated user. The
+// profile is fetched from Clerk on first use and reused for the request.
+func CurrentUser(c *fiber.Ctx) (*clerk.User, error) {
+	loader, ok := c.Locals("userLoader").(*userLoader)
+	if !ok {
+		return nil, errors.New("request is not authenticated with Clerk")
+	}
+
+	loader.once.Do(func() {
+		loader.user, loader.err = loader.client.Users().Read(loader.userID)
+	})
 
-	// Check if public metadata has a role
-	if user.PublicMetadata != nil {
-		if role, ok := user.PublicMetadata["role"].(string); ok {
-			return role
+	return loader.user, loader.err
+}
+
+// UserEmail returns the authenticated user's email address. It comes from
+// the email claim when the token has one, otherwise Clerk sessions fall
+// back to the primary address of the user's profile.
+func UserEmail(c *fiber.Ctx) (string, error) {
+	principal, _ := c.Locals("principal").(*Principal)
+	if principal == nil {
+		return "", nil
+	}
+	if email, ok := principal.Claims["email"].(string); ok && email != "" {
+		return email, nil
+	}
+	if _, ok := c.Locals("userLoader").(*userLoader); !ok {
+		return "", nil
+	}
+
+	user, err := CurrentUser(c)
+	if err != nil {
+		return "", err
+	}
+	for _, address := range user.EmailAddresses {
+		if user.PrimaryEmailAddressID != nil && address.ID == *user.PrimaryEmailAddressID {
+			return address.EmailAddress, nil
 		}
 	}
+	return "", nil
+}
 
-	return defaultRole
+// orgFromClaims returns the active organization and the user's role in it.
+// Clerk session tokens carry them as org_id/org_role, or as o.id/o.rol in
+// version 2 tokens; both are empty without an active organization.
+func orgFromClaims(claims map[string]interface{}) (string, string) {
+	orgID, _ := claimValue(claims, "org_id").(string)
+	orgRole, _ := claimValue(claims, "org_role").(string)
+	if orgID == "" {
+		orgID, _ = claimValue(claims, "o.id").(string)
+		orgRole, _ = claimValue(claims, "o.rol").(string)
+	}
+	return orgID, orgRole
 }
 
-// CheckRLS checks if the current user has access to the requested resource
-// based on Row Level Security policies
-func CheckRLS(db interface{}, user interface{}, resource string, action string) (bool, error) {
-	// This is a placeholder for actual RLS check implementation
-	// In a real implementation, this would query the database for RLS policies
-	// and evaluate them against the current user and requested resource
-	
-	if user == nil {
-		return false, errors.New("user not authenticated")
+// rolesFromClaims extracts the user's roles from the token claims. Clerk
+// session tokens carry them when the session token template includes the
+// user's public metadata, e.g. {"metadata": "{{user.public_metadata}}"},
+// with either a "role" string or a "roles" array.
+func rolesFromClaims(claims map[string]interface{}) []string {
+	var roles []string
+	seen := make(map[string]bool)
+
+	for _, prefix := range []string{"", "metadata.", "public_metadata."} {
+		for _, path := range []string{prefix + "role", prefix + "roles"} {
+			for _, role := range claimStrings(claimValue(claims, path)) {
+				if !seen[role] {
+					seen[role] = true
+					roles = append(roles, role)
+				}
+			}
+		}
 	}
 
-	// For now, we'll just allow all authenticated users
-	return true, nil
+	return roles
 }
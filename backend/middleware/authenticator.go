@@ -0,0 +1,239 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackson/supabase-go/config"
+	"github.com/jackson/supabase-go/db"
+)
+
+// ErrNoCredentials is returned by an Authenticator when the request does not
+// carry the kind of credentials it handles, so the next one is tried
+var ErrNoCredentials = errors.New("no credentials")
+
+// Principal is the identity an Authenticator established for a request
+type Principal struct {
+	UserID    string
+	Role      string
+	Roles     []string
+	SessionID string
+	OrgID     string
+	OrgRole   string
+	Method    string
+	ExpiresAt *time.Time
+	Claims    map[string]interface{}
+}
+
+// Authenticator verifies the credentials of a request
+type Authenticator interface {
+	// Name identifies the provider in AUTH_PROVIDERS and logs
+	Name() string
+	// Authenticate returns the request's principal, or ErrNoCredentials
+	// when the request has no credentials for this provider
+	Authenticate(c *fiber.Ctx) (*Principal, error)
+}
+
+// NewAuthenticators builds the providers listed in AUTH_PROVIDERS, in order.
+// Without AUTH_PROVIDERS every provider that is configured is used. A
+// provider that cannot be built is logged and left out instead of stopping
+// the server.
+func NewAuthenticators(cfg config.AuthConfig, database *db.DB) []Authenticator {
+	providers := cfg.Providers
+	if len(providers) == 0 {
+		providers = defaultProviders(cfg)
+	}
+
+	var authenticators []Authenticator
+	for _, name := range providers {
+		authenticator, err := newAuthenticator(name, cfg, database)
+		if err != nil {
+			log.Printf("Auth provider %q disabled: %v", name, err)
+			continue
+		}
+		authenticators = append(authenticators, authenticator)
+	}
+
+	if len(authenticators) == 0 {
+		log.Printf("No auth providers are enabled, only public routes are reachable")
+	}
+
+	return authenticators
+}
+
+// defaultProviders lists the providers with enough configuration to run.
+// With Clerk configured, JWT_ISSUER alone is Clerk's issuer, as it was
+// before the jwt provider existed, and does not enable jwt.
+func defaultProviders(cfg config.AuthConfig) []string {
+	providers := []string{"api_key"}
+	hasKeys := cfg.JWTPublicKey != "" || cfg.JWKSFile != "" || cfg.JWKSURL != "" || cfg.JWTSecret != ""
+	if hasKeys || (cfg.JWTIssuer != "" && cfg.ClerkSecretKey == "") {
+		providers = append(providers, "jwt")
+	}
+	if cfg.ClerkSecretKey != "" {
+		providers = append(providers, "clerk")
+	}
+	return providers
+}
+
+// newAuthenticator builds a single provider by name
+func newAuthenticator(name string, cfg config.AuthConfig, database *db.DB) (Authenticator, error) {
+	switch name {
+	case "api_key":
+		return NewAPIKeyAuth(database), nil
+	case "clerk":
+		return NewClerkAuthenticator(cfg)
+	case "jwt":
+		return NewJWTAuthenticator(cfg)
+	case "test":
+		return NewTestAuthenticator(cfg)
+	}
+	return nil, fmt.Errorf("unknown provider, expected one of api_key, clerk, jwt or test")
+}
+
+// Authenticate returns a middleware that tries each authenticator in order.
+// The first one that accepts the request's credentials sets the principal;
+// if none does, the first rejection is reported. Requests without any
+// credentials continue as anon on the anon routes when anon is enabled.
+func Authenticate(cfg config.AuthConfig, authenticators []Authenticator) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Skip authentication for public routes
+		path := c.Path()
+		if matchRoute(cfg.PublicRoutes, path) {
+			return c.Next()
+		}
+
+		var firstErr error
+		for _, authenticator := range authenticators {
+			principal, err := authenticator.Authenticate(c)
+			if err == nil {
+				setPrincipal(c, principal)
+				return c.Next()
+			}
+			if err != ErrNoCredentials && firstErr == nil {
+				firstErr = err
+			}
+		}
+
+		if firstErr == nil {
+			// Invalid credentials are rejected above, only requests without
+			// any credentials fall back to anon
+			if cfg.AnonEnabled && matchRoute(cfg.AnonRoutes, path) {
+				setPrincipal(c, &Principal{Role: db.AnonRole, Method: "anon"})
+				return c.Next()
+			}
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Missing authorization header",
+			})
+		}
+		if message, ok := authErrorMessage(firstErr); ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": message,
+			})
+		}
+
+		log.Printf("Failed to authenticate request: %v", firstErr)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to authenticate request",
+		})
+	}
+}
+
+// setPrincipal stores the principal in the locals used by route handlers
+func setPrincipal(c *fiber.Ctx, principal *Principal) {
+	role := principal.Role
+	if role == "" {
+		role = "user"
+	}
+
+	c.Locals("principal", principal)
+	c.Locals("userId", principal.UserID)
+	c.Locals("userRole", role)
+	c.Locals("userRoles", principal.Roles)
+	c.Locals("sessionId", principal.SessionID)
+	c.Locals("orgId", principal.OrgID)
+	c.Locals("orgRole", principal.OrgRole)
+	c.Locals("authMethod", principal.Method)
+	c.Locals("claims", principal.Claims)
+}
+
+// authErrorMessage returns the response message for credential errors
+func authErrorMessage(err error) (string, bool) {
+	switch err {
+	case ErrInvalidToken:
+		return "Invalid token", true
+	case ErrTokenExpired:
+		return "Token has expired", true
+	case ErrUnknownKey:
+		return "Token signed by an unknown key", true
+	case ErrInvalidAPIKey:
+		return "Invalid API key", true
+	case ErrAPIKeyExpired:
+		return "API key has expired", true
+	case ErrAPIKeyRevoked:
+		return "API key has been revoked", true
+	case errInvalidAuthHeader:
+		return "Invalid authorization header format", true
+	}
+	return "", false
+}
+
+// errInvalidAuthHeader is returned for Authorization headers that are not
+// bearer tokens
+var errInvalidAuthHeader = errors.New("invalid authorization header format")
+
+// bearerToken returns the request's bearer token, or ErrNoCredentials when
+// there is no Authorization header
+func bearerToken(c *fiber.Ctx) (string, error) {
+	authHeader := c.Get("Authorization")
+	if authHeader == "" {
+		return "", ErrNoCredentials
+	}
+
+	token := parseAuthHeader(authHeader)
+	if token == "" {
+		return "", errInvalidAuthHeader
+	}
+	return token, nil
+}
+
+// parseAuthHeader extracts the token from the authorization header
+func parseAuthHeader(authHeader string) string {
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return ""
+	}
+	return parts[1]
+}
+
+// matchRoute checks a path against route patterns. A pattern ending in /*
+// matches the prefix itself and everything below it, any other pattern
+// must match exactly. A * segment elsewhere matches any single segment, as
+// in /api/tables/*/rows.
+func matchRoute(patterns []string, path string) bool {
+	parts := strings.Split(path, "/")
+	for _, pattern := range patterns {
+		prefix := strings.TrimSuffix(pattern, "/*")
+		segments := strings.Split(prefix, "/")
+		if len(parts) < len(segments) || (prefix == pattern && len(parts) != len(segments)) {
+			continue
+		}
+
+		matched := true
+		for i, segment := range segments {
+			if segment != "*" && segment != parts[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jackson/supabase-go/config"
+)
+
+func TestDefaultProviders(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.AuthConfig
+		want []string
+	}{
+		{"nothing configured", config.AuthConfig{}, []string{"api_key"}},
+		{"clerk", config.AuthConfig{ClerkSecretKey: "sk_test"}, []string{"api_key", "clerk"}},
+		{"clerk with issuer", config.AuthConfig{ClerkSecretKey: "sk_test", JWTIssuer: testIssuer}, []string{"api_key", "clerk"}},
+		{"issuer only", config.AuthConfig{JWTIssuer: testIssuer}, []string{"api_key", "jwt"}},
+		{"secret", config.AuthConfig{JWTSecret: "secret"}, []string{"api_key", "jwt"}},
+		{"public key", config.AuthConfig{JWTPublicKey: "pem"}, []string{"api_key", "jwt"}},
+		{"jwks file", config.AuthConfig{JWKSFile: "jwks.json"}, []string{"api_key", "jwt"}},
+		{"jwks url and clerk", config.AuthConfig{JWKSURL: "https://example.com/jwks", ClerkSecretKey: "sk_test"}, []string{"api_key", "jwt", "clerk"}},
+		{"test secret", config.AuthConfig{TestSecret: "secret"}, []string{"api_key"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := defaultProviders(tt.cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("defaultProviders() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAuthenticators(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.AuthConfig
+		want []string
+	}{
+		{"defaults", config.AuthConfig{JWTSecret: "secret"}, []string{"api_key", "jwt"}},
+		{"listed order", config.AuthConfig{Providers: []string{"test", "api_key"}, TestSecret: "secret"}, []string{"test", "api_key"}},
+		{"unknown provider", config.AuthConfig{Providers: []string{"ldap", "api_key"}}, []string{"api_key"}},
+		{"test without secret", config.AuthConfig{Providers: []string{"test"}}, nil},
+		{"jwt without keys", config.AuthConfig{Providers: []string{"jwt"}}, nil},
+		{"clerk without issuer", config.AuthConfig{Providers: []string{"clerk"}, ClerkSecretKey: "sk_test"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, authenticator := range NewAuthenticators(tt.cfg, nil) {
+				got = append(got, authenticator.Name())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewAuthenticators() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
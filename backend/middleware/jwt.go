@@ -0,0 +1,362 @@
+package middleware
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+)
+
+// clerkJWKSURL is Clerk's backend API endpoint for the instance JWKS
+const clerkJWKSURL = "https://api.clerk.com/v1/jwks"
+
+const (
+	// jwksCacheTTL is how long a fetched JWKS is used before refetching
+	jwksCacheTTL = time.Hour
+	// jwksMinRefresh limits refetches triggered by unknown key IDs
+	jwksMinRefresh = 30 * time.Second
+)
+
+// asymmetricAlgorithms are accepted when no algorithms are configured
+var asymmetricAlgorithms = []string{
+	string(jose.RS256),
+	string(jose.RS384),
+	string(jose.RS512),
+	string(jose.PS256),
+	string(jose.ES256),
+	string(jose.ES384),
+	string(jose.EdDSA),
+}
+
+// Token errors returned by Verify
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token has expired")
+	ErrUnknownKey   = errors.New("token signed by an unknown key")
+)
+
+// TokenClaims are the verified claims of a JWT
+type TokenClaims struct {
+	jwt.Claims
+	Raw map[string]interface{}
+}
+
+// SessionID returns the session the token belongs to, if any
+func (t *TokenClaims) SessionID() string {
+	sid, _ := t.Raw["sid"].(string)
+	return sid
+}
+
+// ExpiresAt returns the token's expiry time
+func (t *TokenClaims) ExpiresAt() *time.Time {
+	if t.Expiry == nil {
+		return nil
+	}
+	expiry := t.Expiry.Time()
+	return &expiry
+}
+
+// JWTVerifierConfig selects the keys and checks a JWTVerifier uses. Keys
+// come from the first of PublicKey, Secret, JWKSFile, JWKSURL or, when only
+// Issuer is set, the issuer's OpenID configuration.
+type JWTVerifierConfig struct {
+	PublicKey   string // PEM encoded
+	Secret      string // HMAC secret for HS256
+	JWKSFile    string
+	JWKSURL     string
+	BearerToken string // sent when fetching JWKSURL
+	Issuer      string
+	Audience    []string
+	Algorithms  []string
+	ClockSkew   int // seconds
+}
+
+// JWTVerifier verifies JWTs locally against a static key, a JWKS file, or
+// a JWKS fetched over HTTP and cached
+type JWTVerifier struct {
+	issuer     string
+	audience   []string
+	algorithms map[string]bool
+	leeway     time.Duration
+
+	// Remote JWKS, empty when keys are static
+	jwksURL     string
+	discover    bool
+	bearerToken string
+	httpClient  *http.Client
+
+	mu        sync.RWMutex
+	keys      *jose.JSONWebKeySet
+	fetchedAt time.Time
+}
+
+// NewJWTVerifier creates a verifier from its configuration
+func NewJWTVerifier(vc JWTVerifierConfig) (*JWTVerifier, error) {
+	v := &JWTVerifier{
+		issuer:     vc.Issuer,
+		audience:   vc.Audience,
+		algorithms: make(map[string]bool),
+		leeway:     time.Duration(vc.ClockSkew) * time.Second,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	algorithms := vc.Algorithms
+	switch {
+	case vc.PublicKey != "":
+		key, err := parsePublicKey(vc.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %w", err)
+		}
+		v.keys = &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key}}
+	case vc.Secret != "":
+		v.keys = &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: []byte(vc.Secret), Use: "sig"}}}
+		if len(algorithms) == 0 {
+			algorithms = []string{string(jose.HS256)}
+		}
+	case vc.JWKSFile != "":
+		data, err := os.ReadFile(vc.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+		}
+		var keys jose.JSONWebKeySet
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return nil, fmt.Errorf("invalid JWKS file: %w", err)
+		}
+		v.keys = &keys
+	case vc.JWKSURL != "":
+		v.jwksURL = vc.JWKSURL
+		v.bearerToken = vc.BearerToken
+	case vc.Issuer != "":
+		v.discover = true
+	default:
+		return nil, errors.New("no JWT verification key configured")
+	}
+
+	if len(algorithms) == 0 {
+		algorithms = asymmetricAlgorithms
+	}
+	for _, alg := range algorithms {
+		v.algorithms[alg] = true
+	}
+
+	return v, nil
+}
+
+// Verify checks a token's signature, issuer, audience, expiry and not
+// before time and returns its claims
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (*TokenClaims, error) {
+	tok, err := jwt.ParseSigned(token)
+	if err != nil || len(tok.Headers) != 1 {
+		return nil, ErrInvalidToken
+	}
+	header := tok.Headers[0]
+	if !v.algorithms[header.Algorithm] {
+		return nil, ErrInvalidToken
+	}
+
+	key, err := v.key(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &TokenClaims{}
+	if err := tok.Claims(key.Key, &claims.Claims, &claims.Raw); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.Expiry == nil {
+		return nil, ErrInvalidToken
+	}
+	err = claims.ValidateWithLeeway(jwt.Expected{Issuer: v.issuer, Time: time.Now()}, v.leeway)
+	if err == jwt.ErrExpired {
+		return nil, ErrTokenExpired
+	}
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	// Any one of the configured audiences is enough
+	if len(v.audience) > 0 {
+		matched := false
+		for _, aud := range v.audience {
+			if claims.Audience.Contains(aud) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, ErrInvalidToken
+		}
+	}
+
+	return claims, nil
+}
+
+// remote reports whether keys are fetched over HTTP
+func (v *JWTVerifier) remote() bool {
+	return v.jwksURL != "" || v.discover
+}
+
+// key returns the verification key for a key ID, refetching a remote JWKS
+// when it is stale or does not know the key
+func (v *JWTVerifier) key(ctx context.Context, kid string) (*jose.JSONWebKey, error) {
+	v.mu.RLock()
+	keys, fetchedAt := v.keys, v.fetchedAt
+	v.mu.RUnlock()
+
+	var cached *jose.JSONWebKey
+	if keys != nil {
+		cached = findKey(keys, kid)
+		if !v.remote() || (cached != nil && time.Since(fetchedAt) < jwksCacheTTL) {
+			if cached == nil {
+				return nil, ErrUnknownKey
+			}
+			return cached, nil
+		}
+	}
+
+	keys, err := v.refresh(ctx)
+	if err != nil {
+		// Keep using a stale key while the JWKS endpoint is unavailable
+		if cached != nil {
+			return cached, nil
+		}
+		return nil, err
+	}
+	if key := findKey(keys, kid); key != nil {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// refresh fetches the remote JWKS, unless another request just did
+func (v *JWTVerifier) refresh(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.keys != nil && time.Since(v.fetchedAt) < jwksMinRefresh {
+		return v.keys, nil
+	}
+
+	// Look up the JWKS location in the issuer's OpenID configuration
+	if v.jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		url := strings.TrimSuffix(v.issuer, "/") + "/.well-known/openid-configuration"
+		if err := v.fetchJSON(ctx, url, &discovery); err != nil {
+			return nil, err
+		}
+		if discovery.JWKSURI == "" {
+			return nil, fmt.Errorf("OpenID configuration of %s has no jwks_uri", v.issuer)
+		}
+		v.jwksURL = discovery.JWKSURI
+	}
+
+	var keys jose.JSONWebKeySet
+	if err := v.fetchJSON(ctx, v.jwksURL, &keys); err != nil {
+		return nil, err
+	}
+
+	v.keys = &keys
+	v.fetchedAt = time.Now()
+	return v.keys, nil
+}
+
+// fetchJSON GETs a URL and decodes the JSON response into dest
+func (v *JWTVerifier) fetchJSON(ctx context.Context, url string, dest interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if v.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+v.bearerToken)
+	}
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch %s: unexpected status %d", url, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(dest); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", url, err)
+	}
+	return nil
+}
+
+// findKey returns the key with the given ID. A single key without an ID,
+// such as a PEM public key or a secret, matches any token.
+func findKey(keys *jose.JSONWebKeySet, kid string) *jose.JSONWebKey {
+	if kid != "" {
+		if found := keys.Key(kid); len(found) > 0 {
+			return &found[0]
+		}
+	}
+	if len(keys.Keys) == 1 && (kid == "" || keys.Keys[0].KeyID == "") {
+		return &keys.Keys[0]
+	}
+	return nil
+}
+
+// parsePublicKey parses a PEM encoded public key. Newlines may be escaped
+// as \n so the key fits in a single environment variable.
+func parsePublicKey(value string) (jose.JSONWebKey, error) {
+	block, _ := pem.Decode([]byte(strings.ReplaceAll(value, `\n`, "\n")))
+	if block == nil {
+		return jose.JSONWebKey{}, errors.New("no PEM block found")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return jose.JSONWebKey{}, err
+	}
+
+	return jose.JSONWebKey{Key: key, Use: "sig"}, nil
+}
+
+// claimValue returns the claim at a dotted path such as "realm_access.roles"
+func claimValue(claims map[string]interface{}, path string) interface{} {
+	var value interface{} = claims
+	for _, part := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[part]
+	}
+	return value
+}
+
+// claimStrings returns a string or array of strings claim as a slice
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/jackson/supabase-go/config"
+)
+
+const testIssuer = "https://issuer.example.com"
+
+// newRSAKey generates a signing key with its public JWK
+func newRSAKey(t *testing.T, kid string) (*rsa.PrivateKey, jose.JSONWebKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+	return key, jose.JSONWebKey{Key: &key.PublicKey, KeyID: kid, Algorithm: string(jose.RS256), Use: "sig"}
+}
+
+// signToken signs claims with an RS256 key, or HS256 when key is a []byte
+func signToken(t *testing.T, key interface{}, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	alg := jose.RS256
+	if _, ok := key.([]byte); ok {
+		alg = jose.HS256
+	}
+	opts := (&jose.SignerOptions{}).WithType("JWT")
+	if kid != "" {
+		opts = opts.WithHeader("kid", kid)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key}, opts)
+	if err != nil {
+		t.Fatalf("jose.NewSigner: %v", err)
+	}
+	token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	if err != nil {
+		t.Fatalf("CompactSerialize: %v", err)
+	}
+	return token
+}
+
+// claimsWith returns valid claims for testIssuer with the given overrides
+func claimsWith(overrides map[string]interface{}) map[string]interface{} {
+	now := time.Now()
+	claims := map[string]interface{}{
+		"sub": "user_1",
+		"iss": testIssuer,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for name, value := range overrides {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+	return claims
+}
+
+func TestJWTVerifierJWKS(t *testing.T) {
+	key, jwk := newRSAKey(t, "key-1")
+	otherKey, _ := newRSAKey(t, "key-1")
+	keys := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}}
+
+	data, err := json.Marshal(keys)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(jwksFile, data, 0o600); err != nil {
+		t.Fatalf("os.WriteFile: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	past := time.Now().Add(-2 * time.Hour)
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"valid", signToken(t, key, "key-1", claimsWith(nil)), nil},
+		{"bad signature", signToken(t, otherKey, "key-1", claimsWith(nil)), ErrInvalidToken},
+		{"unknown key", signToken(t, key, "key-2", claimsWith(nil)), ErrUnknownKey},
+		{"expired", signToken(t, key, "key-1", claimsWith(map[string]interface{}{
+			"iat": past.Unix(),
+			"exp": past.Add(time.Hour).Unix(),
+		})), ErrTokenExpired},
+		{"no expiry", signToken(t, key, "key-1", claimsWith(map[string]interface{}{"exp": nil})), ErrInvalidToken},
+		{"wrong issuer", signToken(t, key, "key-1", claimsWith(map[string]interface{}{"iss": "https://evil.example.com"})), ErrInvalidToken},
+		{"no issuer", signToken(t, key, "key-1", claimsWith(map[string]interface{}{"iss": nil})), ErrInvalidToken},
+		{"symmetric algorithm", signToken(t, []byte("secret"), "key-1", claimsWith(nil)), ErrInvalidToken},
+		{"malformed", "not.a.token", ErrInvalidToken},
+	}
+
+	sources := map[string]JWTVerifierConfig{
+		"file": {JWKSFile: jwksFile, Issuer: testIssuer},
+		"url":  {JWKSURL: server.URL, BearerToken: "secret", Issuer: testIssuer},
+	}
+	for source, vc := range sources {
+		verifier, err := NewJWTVerifier(vc)
+		if err != nil {
+			t.Fatalf("%s: NewJWTVerifier: %v", source, err)
+		}
+
+		for _, tt := range tests {
+			t.Run(source+"/"+tt.name, func(t *testing.T) {
+				claims, err := verifier.Verify(context.Background(), tt.token)
+				if err != tt.wantErr {
+					t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+				}
+				if err == nil && claims.Subject != "user_1" {
+					t.Errorf("Verify() subject = %q, want %q", claims.Subject, "user_1")
+				}
+			})
+		}
+	}
+}
+
+func TestJWTVerifierSecret(t *testing.T) {
+	secret := []byte("a-test-secret-that-is-long-enough")
+	verifier, err := NewJWTVerifier(JWTVerifierConfig{Secret: string(secret), Issuer: testIssuer, Audience: []string{"api"}})
+	if err != nil {
+		t.Fatalf("NewJWTVerifier: %v", err)
+	}
+	key, _ := newRSAKey(t, "")
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"valid", signToken(t, secret, "", claimsWith(map[string]interface{}{"aud": "api"})), nil},
+		{"wrong secret", signToken(t, []byte("another-secret-that-is-long-enough"), "", claimsWith(map[string]interface{}{"aud": "api"})), ErrInvalidToken},
+		{"wrong audience", signToken(t, secret, "", claimsWith(map[string]interface{}{"aud": "web"})), ErrInvalidToken},
+		{"no audience", signToken(t, secret, "", claimsWith(nil)), ErrInvalidToken},
+		{"asymmetric algorithm", signToken(t, key, "", claimsWith(map[string]interface{}{"aud": "api"})), ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := verifier.Verify(context.Background(), tt.token); err != tt.wantErr {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignTestToken(t *testing.T) {
+	authenticator, err := NewTestAuthenticator(config.AuthConfig{TestSecret: "test-secret"})
+	if err != nil {
+		t.Fatalf("NewTestAuthenticator: %v", err)
+	}
+
+	token, err := SignTestToken("test-secret", map[string]interface{}{"sub": "user_1"}, time.Minute)
+	if err != nil {
+		t.Fatalf("SignTestToken: %v", err)
+	}
+	if _, err := authenticator.verifier.Verify(context.Background(), token); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	expired, err := SignTestToken("test-secret", map[string]interface{}{"sub": "user_1"}, -time.Minute)
+	if err != nil {
+		t.Fatalf("SignTestToken: %v", err)
+	}
+	if _, err := authenticator.verifier.Verify(context.Background(), expired); err != ErrTokenExpired {
+		t.Errorf("Verify() error = %v, want %v", err, ErrTokenExpired)
+	}
+}
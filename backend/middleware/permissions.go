@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AnalystRole is the application role with read-only access to the admin
+// surfaces
+const AnalystRole = "analyst"
+
+// RoutePermission lists the roles allowed to call the routes matching a
+// method and a path pattern
+type RoutePermission struct {
+	// Method is the HTTP method, empty for any. GET also covers HEAD.
+	Method string
+	// Path is a route pattern, a trailing /* matches a prefix
+	Path string
+	// Roles have full access to the matching routes
+	Roles []string
+	// ReadOnlyRoles are let through in read-only mode, which handlers check
+	// with IsReadOnly
+	ReadOnlyRoles []string
+}
+
+// matches reports whether the permission applies to a request
+func (p RoutePermission) matches(method, path string) bool {
+	if p.Method != "" && p.Method != method && !(p.Method == fiber.MethodGet && method == fiber.MethodHead) {
+		return false
+	}
+	return matchRoute([]string{p.Path}, path)
+}
+
+// required returns every role the permission accepts
+func (p RoutePermission) required() []string {
+	return append(append([]string{}, p.Roles...), p.ReadOnlyRoles...)
+}
+
+// Authorize returns a middleware that enforces route permissions. The first
+// permission matching the request decides; requests no permission matches
+// only need to be authenticated.
+func Authorize(permissions []RoutePermission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		permission, ok := findPermission(permissions, c.Method(), routePath(c))
+		if !ok {
+			return c.Next()
+		}
+
+		for _, role := range permission.Roles {
+			if HasRole(c, role) {
+				return c.Next()
+			}
+		}
+		for _, role := range permission.ReadOnlyRoles {
+			if HasRole(c, role) {
+				c.Locals("readOnly", true)
+				return c.Next()
+			}
+		}
+
+		return forbidden(c, permission.required())
+	}
+}
+
+// IsReadOnly reports whether the request was only granted read-only access
+func IsReadOnly(c *fiber.Ctx) bool {
+	readOnly, _ := c.Locals("readOnly").(bool)
+	return readOnly
+}
+
+// routePath returns the request path the way the router matches it:
+// lowercased unless routing is case-sensitive, and without trailing slashes
+// unless routing is strict. Otherwise /API/query or /api/query/ would reach
+// the handler without matching any permission.
+func routePath(c *fiber.Ctx) string {
+	path := c.Path()
+	config := c.App().Config()
+	if !config.CaseSensitive {
+		path = strings.ToLower(path)
+	}
+	if !config.StrictRouting {
+		path = strings.TrimRight(path, "/")
+		if path == "" {
+			path = "/"
+		}
+	}
+	return path
+}
+
+// findPermission returns the first permission matching a request
+func findPermission(permissions []RoutePermission, method, path string) (RoutePermission, bool) {
+	for _, permission := range permissions {
+		if permission.matches(method, path) {
+			return permission, true
+		}
+	}
+	return RoutePermission{}, false
+}
+
+// forbidden writes the response for requests without a required role
+func forbidden(c *fiber.Ctx, roles []string) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error":          "Insufficient permissions",
+		"required_roles": roles,
+	})
+}
+
+// LogRoutePermissions logs every registered route with the roles it
+// requires, so the effective permissions can be reviewed at startup
+func LogRoutePermissions(app *fiber.App, permissions []RoutePermission) {
+	log.Printf("Route permissions:")
+	for _, route := range app.GetRoutes(true) {
+		if route.Method == fiber.MethodHead {
+			continue
+		}
+
+		required := "no role required"
+		if permission, ok := findPermission(permissions, route.Method, route.Path); ok {
+			required = strings.Join(permission.Roles, ", ")
+			if len(permission.ReadOnlyRoles) > 0 {
+				required += " (read-only: " + strings.Join(permission.ReadOnlyRoles, ", ") + ")"
+			}
+		}
+		log.Printf("  %-6s %-45s %s", route.Method, route.Path, required)
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"fmt"
 	"net/url"
 
 	"github.com/gofiber/fiber/v2"
@@ -18,8 +19,13 @@ func QueryParamsMiddleware() fiber.Handler {
 			})
 		}
 
-		// Parse into QueryParams struct
-		queryParams := querybuilder.ParseQueryParams(values)
+		// Parse into a Query
+		queryParams, err := querybuilder.ParseWithLimit(values, querybuilder.QueryParamsLimit)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid query: %v", err),
+			})
+		}
 
 		// Store in context for handlers to use
 		c.Locals("queryParams", queryParams)
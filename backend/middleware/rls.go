@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackson/supabase-go/db"
+)
+
+// RLSDecision is the outcome of evaluating RLS policies for a request
+type RLSDecision struct {
+	Allowed  bool
+	Policies []string
+}
+
+// rlsPolicy is the subset of an rls_policies row needed for evaluation
+type rlsPolicy struct {
+	Name       string
+	Action     string
+	Roles      []string
+	Permissive bool
+}
+
+// rlsCacheTTL bounds how long policies changed outside this process, for
+// example by the admin CLI or directly in SQL, can stay cached
+const rlsCacheTTL = time.Minute
+
+// rlsCacheEntry holds the cached policies for one table
+type rlsCacheEntry struct {
+	policies []rlsPolicy
+	loadedAt time.Time
+}
+
+// RLSEngine evaluates the policies stored in rls_policies against the
+// caller's role. Policies are cached per table until invalidated or until
+// rlsCacheTTL has passed.
+type RLSEngine struct {
+	db    *db.DB
+	mu    sync.RWMutex
+	cache map[string]rlsCacheEntry
+}
+
+// NewRLSEngine creates a policy engine backed by the rls_policies table
+func NewRLSEngine(database *db.DB) *RLSEngine {
+	return &RLSEngine{
+		db:    database,
+		cache: make(map[string]rlsCacheEntry),
+	}
+}
+
+// CheckRLS decides whether role may perform action on table. Tables without
+// any stored policies are allowed, matching PostgreSQL when RLS is disabled.
+// Otherwise at least one permissive policy for the action (or 'all') must
+// grant the role, either directly or through 'public'. Restrictive policies
+// only narrow the visible rows, so they are reported but never grant access.
+func (e *RLSEngine) CheckRLS(ctx context.Context, table, action, role string) (RLSDecision, error) {
+	policies, err := e.policies(ctx, table)
+	if err != nil {
+		return RLSDecision{}, err
+	}
+
+	if len(policies) == 0 {
+		return RLSDecision{Allowed: true}, nil
+	}
+
+	var decision RLSDecision
+	for _, policy := range policies {
+		if policy.Action != action && policy.Action != "all" {
+			continue
+		}
+		if !policyGrantsRole(policy, role) {
+			continue
+		}
+		if policy.Permissive {
+			decision.Allowed = true
+		}
+		decision.Policies = append(decision.Policies, policy.Name)
+	}
+
+	return decision, nil
+}
+
+// Invalidate drops the cached policies for a table
+func (e *RLSEngine) Invalidate(table string) {
+	e.mu.Lock()
+	delete(e.cache, table)
+	e.mu.Unlock()
+}
+
+// InvalidateAll drops the cached policies for every table
+func (e *RLSEngine) InvalidateAll() {
+	e.mu.Lock()
+	e.cache = make(map[string]rlsCacheEntry)
+	e.mu.Unlock()
+}
+
+// policies returns the policies for a table, loading them if not cached
+func (e *RLSEngine) policies(ctx context.Context, table string) ([]rlsPolicy, error) {
+	e.mu.RLock()
+	entry, ok := e.cache[table]
+	e.mu.RUnlock()
+	if ok && time.Since(entry.loadedAt) < rlsCacheTTL {
+		return entry.policies, nil
+	}
+
+	rows, err := e.db.Query(ctx, `
+		SELECT name, action, roles, permissive
+		FROM rls_policies
+		WHERE table_name = $1
+		ORDER BY name
+	`, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load RLS policies: %w", err)
+	}
+	defer rows.Close()
+
+	policies := []rlsPolicy{}
+	for rows.Next() {
+		var policy rlsPolicy
+		var rolesJson string
+		if err := rows.Scan(&policy.Name, &policy.Action, &rolesJson, &policy.Permissive); err != nil {
+			return nil, fmt.Errorf("failed to scan RLS policy: %w", err)
+		}
+		if err := json.Unmarshal([]byte(rolesJson), &policy.Roles); err != nil {
+			return nil, fmt.Errorf("invalid roles for RLS policy %s: %w", policy.Name, err)
+		}
+		policies = append(policies, policy)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating RLS policies: %w", err)
+	}
+
+	e.mu.Lock()
+	e.cache[table] = rlsCacheEntry{policies: policies, loadedAt: time.Now()}
+	e.mu.Unlock()
+
+	return policies, nil
+}
+
+// policyGrantsRole reports whether a policy applies to role
+func policyGrantsRole(policy rlsPolicy, role string) bool {
+	for _, r := range policy.Roles {
+		if r == role || r == "public" {
+			return true
+		}
+	}
+	return false
+}
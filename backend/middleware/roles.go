@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackson/supabase-go/db"
+)
+
+// AdminRole is the application role allowed to manage keys and policies
+const AdminRole = "admin"
+
+// UserRoles are the resolved roles of the authenticated user
+type UserRoles struct {
+	// Roles is the union of the roles from the credentials and user_roles
+	Roles []string `json:"roles"`
+	// Primary is the PostgreSQL role requests run as
+	Primary string `json:"primary"`
+}
+
+// Has reports whether role is one of the user's roles
+func (r *UserRoles) Has(role string) bool {
+	for _, userRole := range r.Roles {
+		if userRole == role {
+			return true
+		}
+	}
+	return false
+}
+
+// roleLoader resolves the roles of a request at most once
+type roleLoader struct {
+	once  sync.Once
+	db    *db.DB
+	roles *UserRoles
+	err   error
+}
+
+// LoadUserRoles returns a middleware that lets handlers resolve the user's
+// roles from the credentials and the user_roles table. Roles are only
+// looked up when first needed and are then reused for the request.
+func LoadUserRoles(database *db.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals("roleLoader", &roleLoader{db: database})
+		return c.Next()
+	}
+}
+
+// ResolveRoles returns the roles of the authenticated user
+func ResolveRoles(c *fiber.Ctx) (*UserRoles, error) {
+	principal, _ := c.Locals("principal").(*Principal)
+	if principal == nil || principal.UserID == "" {
+		return &UserRoles{Roles: []string{db.AnonRole}, Primary: db.AnonRole}, nil
+	}
+
+	loader, ok := c.Locals("roleLoader").(*roleLoader)
+	if !ok {
+		// Without the loader only the roles from the credentials are known
+		return resolveRoles(context.Background(), nil, principal)
+	}
+
+	loader.once.Do(func() {
+		loader.roles, loader.err = resolveRoles(context.Background(), loader.db, principal)
+	})
+	return loader.roles, loader.err
+}
+
+// resolveRoles merges the principal's roles with its user_roles rows and
+// picks the primary role. The primary role is the first of the primary
+// user_roles row, the credentials' role and the remaining roles that
+// PostgreSQL lets requests switch to, falling back to authenticated.
+func resolveRoles(ctx context.Context, database *db.DB, principal *Principal) (*UserRoles, error) {
+	var candidates []string
+	seen := make(map[string]bool)
+	add := func(role string) {
+		if role != "" && role != "user" && !seen[role] {
+			seen[role] = true
+			candidates = append(candidates, role)
+		}
+	}
+
+	var stored []string
+	var primaryRow string
+	if database != nil {
+		rows, err := database.Query(ctx, `
+			SELECT role, is_primary
+			FROM user_roles
+			WHERE user_id = $1
+			ORDER BY created_at
+		`, principal.UserID)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var role string
+			var isPrimary bool
+			if err := rows.Scan(&role, &isPrimary); err != nil {
+				return nil, err
+			}
+			if isPrimary {
+				primaryRow = role
+			}
+			stored = append(stored, role)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	add(primaryRow)
+	add(principal.Role)
+	for _, role := range principal.Roles {
+		add(role)
+	}
+	for _, role := range stored {
+		add(role)
+	}
+
+	roles := &UserRoles{Roles: candidates, Primary: db.AuthenticatedRole}
+	if roles.Roles == nil {
+		roles.Roles = []string{}
+	}
+	if len(candidates) == 0 {
+		return roles, nil
+	}
+	if database == nil {
+		roles.Primary = candidates[0]
+		return roles, nil
+	}
+
+	usable, err := UsableRoles(ctx, database, candidates)
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range candidates {
+		if usable[role] {
+			roles.Primary = role
+			break
+		}
+	}
+
+	return roles, nil
+}
+
+// UsableRoles returns which of the given roles may be used as a primary
+// role: roles the server may switch to, other than its own login role, and
+// that cannot bypass RLS
+func UsableRoles(ctx context.Context, database *db.DB, roles []string) (map[string]bool, error) {
+	rows, err := database.Query(ctx, `
+		SELECT rolname
+		FROM pg_roles
+		WHERE rolname = ANY($1)
+		AND NOT rolsuper
+		AND NOT rolbypassrls
+		AND pg_has_role(rolname, 'MEMBER')
+		AND rolname <> session_user
+	`, roles)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usable := make(map[string]bool)
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		usable[role] = true
+	}
+
+	return usable, rows.Err()
+}
+
+// RequireRole returns a middleware that only lets requests through whose
+// user has one of the given roles
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		for _, role := range roles {
+			if HasRole(c, role) {
+				return c.Next()
+			}
+		}
+
+		return forbidden(c, roles)
+	}
+}
+
+// HasRole reports whether the authenticated user has the given role, from
+// their credentials or the user_roles table
+func HasRole(c *fiber.Ctx, role string) bool {
+	roles, err := ResolveRoles(c)
+	if err != nil {
+		log.Printf("Failed to resolve user roles: %v", err)
+		return false
+	}
+	return roles.Has(role)
+}
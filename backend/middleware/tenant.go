@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackson/supabase-go/config"
+)
+
+// ErrNoOrganization is returned for requests on tenant tables that have no
+// active organization
+var ErrNoOrganization = errors.New("an active organization is required")
+
+// TenantScope scopes the rows of the configured tables to the request's
+// active organization through a tenant column, independently of RLS
+type TenantScope struct {
+	column string
+	tables map[string]bool
+}
+
+// NewTenantScope creates the tenant scope from TENANT_TABLES and
+// TENANT_COLUMN. Without tables no table is scoped.
+func NewTenantScope(cfg config.TenantConfig) *TenantScope {
+	tables := make(map[string]bool, len(cfg.Tables))
+	for _, table := range cfg.Tables {
+		tables[table] = true
+	}
+	return &TenantScope{column: cfg.Column, tables: tables}
+}
+
+// Applies reports whether table is scoped to the organization
+func (s *TenantScope) Applies(table string) bool {
+	return s != nil && s.tables[table]
+}
+
+// Column returns the tenant column
+func (s *TenantScope) Column() string {
+	return s.column
+}
+
+// OrgID returns the active organization of the request, or
+// ErrNoOrganization when there is none
+func (s *TenantScope) OrgID(c *fiber.Ctx) (string, error) {
+	orgID, _ := c.Locals("orgId").(string)
+	if orgID == "" {
+		return "", ErrNoOrganization
+	}
+	return orgID, nil
+}
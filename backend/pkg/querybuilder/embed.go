@@ -0,0 +1,152 @@
+package querybuilder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Embed is a related table embedded in the rows of a query, written
+// alias:table!hint!inner(columns) in the select parameter. Its Query holds
+// the embedded columns and nested embeds, and the filters, order and
+// pagination given as table.column=..., table.order=... and table.limit=...
+type Embed struct {
+	// Name is the related table, or a foreign key constraint or column
+	// naming the relationship
+	Name  string
+	Alias string
+	// Hint picks one of several relationships by foreign key constraint,
+	// column or join table
+	Hint string
+	// Inner drops parent rows without a matching embedded row
+	Inner bool
+	Query
+}
+
+// ResultName returns the key holding the embedded rows in each parent row
+func (e *Embed) ResultName() string {
+	if e.Alias != "" {
+		return e.Alias
+	}
+	return e.Name
+}
+
+// parseEmbed parses an embed select element such as author:users!inner(id)
+func parseEmbed(item string, depth int) (*Embed, error) {
+	open := strings.Index(item, "(")
+	if !strings.HasSuffix(item, ")") {
+		return nil, fmt.Errorf("embedded resource %q must end with )", item)
+	}
+
+	embed := &Embed{}
+	head := strings.TrimSpace(item[:open])
+	if i := strings.Index(head, ":"); i >= 0 {
+		embed.Alias, head = strings.TrimSpace(head[:i]), strings.TrimSpace(head[i+1:])
+		if embed.Alias == "" {
+			return nil, fmt.Errorf("empty alias")
+		}
+	}
+
+	parts := strings.Split(head, "!")
+	embed.Name = strings.TrimSpace(parts[0])
+	if embed.Name == "" {
+		return nil, fmt.Errorf("embedded resource %q has no name", item)
+	}
+	for _, modifier := range parts[1:] {
+		switch modifier = strings.TrimSpace(modifier); {
+		case modifier == "inner":
+			embed.Inner = true
+		case modifier == "left":
+		case modifier == "" || embed.Hint != "":
+			return nil, fmt.Errorf("invalid hint in %q", head)
+		default:
+			embed.Hint = modifier
+		}
+	}
+
+	fields, embeds, err := parseSelectList(item[open+1:len(item)-1], depth+1)
+	if err != nil {
+		return nil, err
+	}
+	embed.Select, embed.Embeds = fields, embeds
+	return embed, nil
+}
+
+// embedTarget follows the embed names prefixing key, so comments.status
+// addresses the status column of the comments embed. It returns the query
+// the remaining key applies to. A remainder naming an operator is the
+// col.op filter syntax on the parent instead.
+func (q *Query) embedTarget(key string) (*Query, string) {
+	target := q
+	for {
+		i := strings.Index(key, ".")
+		if i <= 0 {
+			return target, key
+		}
+		if _, ok := operators[key[i+1:]]; ok {
+			return target, key
+		}
+
+		embed := target.embed(key[:i])
+		if embed == nil {
+			return target, key
+		}
+		target, key = &embed.Query, key[i+1:]
+	}
+}
+
+// embed returns the embed with the given result or table name
+func (q *Query) embed(name string) *Embed {
+	for _, embed := range q.Embeds {
+		if embed.ResultName() == name {
+			return embed
+		}
+	}
+	for _, embed := range q.Embeds {
+		if embed.Name == name {
+			return embed
+		}
+	}
+	return nil
+}
+
+// parseEmbedParam applies a parameter addressed to an embedded resource:
+// a filter, a logic group, order, limit or offset
+func (q *Query) parseEmbedParam(key, value string) error {
+	switch {
+	case key == "order":
+		orders, err := parseOrderList(value)
+		if err != nil {
+			return err
+		}
+		q.Order = orders
+	case key == "limit" || key == "offset":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid embedded %s %q", key, value)
+		}
+		if key == "offset" {
+			q.Offset = n
+		} else {
+			q.Limit = n
+			if q.Limit > MaxLimit {
+				q.Limit = MaxLimit
+			}
+		}
+	case logicKeys[key]:
+		group, err := parseLogic(key, value)
+		if err != nil {
+			return err
+		}
+		q.Groups = append(q.Groups, group)
+	case reserved[key]:
+		return fmt.Errorf("%s is not supported on embedded resources", key)
+	default:
+		filter, err := parseFilter(key, value)
+		if err != nil {
+			return err
+		}
+		q.Filters = append(q.Filters, filter)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package querybuilder
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// reserved are the parameters that do not filter columns
+var reserved = map[string]bool{
+	"limit":      true,
+	"offset":     true,
+	"page":       true,
+	"page_size":  true,
+	"order_by":   true,
+	"order_dir":  true,
+	"sort_by":    true,
+	"sort_order": true,
+	"q":          true,
+	"select":     true,
+	"order":      true,
+}
+
+// logicKeys are the parameters holding PostgREST logic trees
+var logicKeys = map[string]bool{
+	"or":      true,
+	"and":     true,
+	"not.or":  true,
+	"not.and": true,
+}
+
+// Parse parses URL query parameters into a Query. Filters are written in
+// the PostgREST syntax col=op.value, or as col.op=value or col__op=value,
+// and a bare col=value tests equality. The PostgREST or/and parameters add
+// grouped conditions. Pagination accepts limit/offset or page/page_size,
+// and sorting order=col.desc, order_by/order_dir or sort_by/sort_order.
+// Parameters prefixed with an embedded resource, such as comments.order,
+// apply to that resource.
+func Parse(values url.Values) (*Query, error) {
+	return ParseWithLimit(values, DefaultLimit)
+}
+
+// ParseWithLimit is Parse with the limit used when the request sets none
+func ParseWithLimit(values url.Values, defaultLimit int) (*Query, error) {
+	q := &Query{Limit: defaultLimit}
+
+	if err := parsePagination(values, q); err != nil {
+		return nil, err
+	}
+	if err := parseOrder(values, q); err != nil {
+		return nil, err
+	}
+	q.Search = values.Get("q")
+
+	if raw := values.Get("select"); raw != "" && raw != "*" {
+		fields, embeds, err := parseSelect(raw)
+		if err != nil {
+			return nil, err
+		}
+		q.Select, q.Embeds = fields, embeds
+	}
+
+	// Sort the keys so the generated SQL does not depend on map order
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if !reserved[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, value := range values[key] {
+			// Parameters prefixed with an embed name apply to the embed
+			if target, rest := q.embedTarget(key); target != q {
+				if err := target.parseEmbedParam(rest, value); err != nil {
+					return nil, fmt.Errorf("invalid parameter %q: %v", key, err)
+				}
+				continue
+			}
+
+			if logicKeys[key] {
+				group, err := parseLogic(key, value)
+				if err != nil {
+					return nil, err
+				}
+				q.Groups = append(q.Groups, group)
+				continue
+			}
+
+			filter, err := parseFilter(key, value)
+			if err != nil {
+				return nil, err
+			}
+			q.Filters = append(q.Filters, filter)
+		}
+	}
+
+	if err := q.checkBounds(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// parsePagination reads limit/offset, falling back to page/page_size.
+// Invalid limits and offsets are errors and limits above MaxLimit are
+// clamped, while page and page_size keep their original leniency: values
+// that are not numbers or out of range fall back to the defaults.
+func parsePagination(values url.Values, q *Query) error {
+	if values.Get("limit") == "" && values.Get("offset") == "" {
+		pageSize, _ := strconv.Atoi(values.Get("page_size"))
+		if pageSize >= 1 && pageSize <= MaxLimit {
+			q.Limit = pageSize
+		}
+	} else if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid limit %q", raw)
+		}
+		if limit > 0 {
+			q.Limit = limit
+		}
+		if q.Limit > MaxLimit {
+			q.Limit = MaxLimit
+		}
+	}
+
+	if raw := values.Get("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid offset %q", raw)
+		}
+		if offset > 0 {
+			q.Offset = offset
+		}
+	} else if page, _ := strconv.Atoi(values.Get("page")); page > 1 {
+		q.Offset = (page - 1) * q.Limit
+	}
+
+	return nil
+}
+
+// parseOrder reads order, falling back to order_by/order_dir and
+// sort_by/sort_order
+func parseOrder(values url.Values, q *Query) error {
+	if raw := values.Get("order"); raw != "" {
+		orders, err := parseOrderList(raw)
+		if err != nil {
+			return err
+		}
+		q.Order = orders
+		return nil
+	}
+
+	column, direction := values.Get("order_by"), values.Get("order_dir")
+	if column == "" {
+		column, direction = values.Get("sort_by"), values.Get("sort_order")
+	}
+	if column == "" {
+		return nil
+	}
+
+	order := Order{Column: column}
+	switch strings.ToLower(direction) {
+	case "", "asc":
+	case "desc":
+		order.Descending = true
+	default:
+		return fmt.Errorf("invalid sort direction %q, expected asc or desc", direction)
+	}
+
+	q.Order = append(q.Order, order)
+	return nil
+}
+
+// parseFilter parses a col.op=value, col__op=value, col=op.value or
+// col=value parameter. A key ending in an operator takes precedence, so
+// col.eq=eq.1 compares with the literal "eq.1".
+func parseFilter(key, value string) (Filter, error) {
+	column, name, dunder := key, "", false
+	if i := strings.LastIndex(key, "__"); i > 0 {
+		column, name, dunder = key[:i], key[i+2:], true
+	} else if i := strings.LastIndex(key, "."); i > 0 {
+		column, name = key[:i], key[i+1:]
+	}
+
+	if _, ok := operators[name]; !ok {
+		if isPostgRESTValue(value) {
+			return parsePostgRESTFilter(key, value)
+		}
+		if name != "" {
+			return Filter{}, fmt.Errorf("invalid filter %q: unknown operator %q", key, name)
+		}
+		name = "eq"
+	}
+
+	op, err := ParseOperator(name)
+	if err != nil {
+		return Filter{}, fmt.Errorf("invalid filter %q: %v", key, err)
+	}
+
+	filter := Filter{Column: column, Operator: op, Value: value}
+	switch op {
+	case OpLike, OpILike:
+		// Both syntaxes match the value anywhere; col__like has always
+		// been case-insensitive
+		filter.Value = "%" + value + "%"
+		if dunder {
+			filter.Operator = OpILike
+		}
+	case OpIn:
+		filter.Value = ""
+		for _, v := range strings.Split(value, ",") {
+			filter.Values = append(filter.Values, strings.TrimSpace(v))
+		}
+	case OpIs:
+		if _, ok := isValues[strings.ToLower(value)]; !ok {
+			return Filter{}, fmt.Errorf("invalid filter %q: is expects null, true, false or unknown", key)
+		}
+	}
+
+	return filter, nil
+}
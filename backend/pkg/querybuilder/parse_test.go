@@ -0,0 +1,69 @@
+package querybuilder
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParseEmptyValue(t *testing.T) {
+	tests := []struct {
+		query string
+		want  Filter
+	}{
+		{"name=", Filter{Column: "name", Operator: OpEq, Value: ""}},
+		{"name=eq.", Filter{Column: "name", Operator: OpEq, Value: ""}},
+		{"name.neq=", Filter{Column: "name", Operator: OpNeq, Value: ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("url.ParseQuery: %v", err)
+			}
+			q, err := Parse(values)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if len(q.Filters) != 1 || !reflect.DeepEqual(q.Filters[0], tt.want) {
+				t.Errorf("Parse() filters = %+v, want [%+v]", q.Filters, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		defaultLimit int
+		wantLimit    int
+		wantOffset   int
+	}{
+		{"default", "", DefaultLimit, 10, 0},
+		{"query params default", "", QueryParamsLimit, 25, 0},
+		{"query params page", "page=3", QueryParamsLimit, 25, 50},
+		{"limit", "limit=5&offset=10", QueryParamsLimit, 5, 10},
+		{"limit above max", "limit=500", DefaultLimit, MaxLimit, 0},
+		{"page size", "page=2&page_size=20", DefaultLimit, 20, 20},
+		{"page size out of range", "page_size=500", DefaultLimit, 10, 0},
+		{"page size not a number", "page_size=ten", QueryParamsLimit, 25, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("url.ParseQuery: %v", err)
+			}
+			q, err := ParseWithLimit(values, tt.defaultLimit)
+			if err != nil {
+				t.Fatalf("ParseWithLimit() error = %v", err)
+			}
+			if q.Limit != tt.wantLimit || q.Offset != tt.wantOffset {
+				t.Errorf("ParseWithLimit() limit, offset = %d, %d, want %d, %d", q.Limit, q.Offset, tt.wantLimit, tt.wantOffset)
+			}
+		})
+	}
+}
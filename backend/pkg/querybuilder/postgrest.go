@@ -0,0 +1,259 @@
+package querybuilder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// isPostgRESTValue reports whether a parameter value is a PostgREST
+// operator expression such as eq.1 or not.in.(1,2)
+func isPostgRESTValue(value string) bool {
+	value = strings.TrimPrefix(value, "not.")
+	i := strings.Index(value, ".")
+	if i <= 0 {
+		return false
+	}
+	_, ok := operators[value[:i]]
+	return ok
+}
+
+// parsePostgRESTFilter parses a [not.]op.value expression on column
+func parsePostgRESTFilter(column, expr string) (Filter, error) {
+	filter := Filter{Column: column}
+	if strings.HasPrefix(expr, "not.") {
+		filter.Negated = true
+		expr = expr[len("not."):]
+	}
+
+	i := strings.Index(expr, ".")
+	if i <= 0 {
+		return Filter{}, fmt.Errorf("invalid filter on %q: expected operator.value", column)
+	}
+	op, err := ParseOperator(expr[:i])
+	if err != nil {
+		return Filter{}, fmt.Errorf("invalid filter on %q: %v", column, err)
+	}
+	filter.Operator = op
+	value := expr[i+1:]
+
+	switch op {
+	case OpLike, OpILike:
+		// PostgREST uses * as the wildcard since % is awkward in URLs
+		filter.Value = strings.ReplaceAll(unquote(value), "*", "%")
+	case OpIn:
+		if !strings.HasPrefix(value, "(") || !strings.HasSuffix(value, ")") {
+			return Filter{}, fmt.Errorf("invalid filter on %q: in expects a list like (1,2,3)", column)
+		}
+		items, err := splitList(value[1 : len(value)-1])
+		if err != nil {
+			return Filter{}, fmt.Errorf("invalid filter on %q: %v", column, err)
+		}
+		if len(items) == 0 {
+			return Filter{}, fmt.Errorf("invalid filter on %q: in expects at least one value", column)
+		}
+		for _, item := range items {
+			filter.Values = append(filter.Values, unquote(item))
+		}
+	case OpIs:
+		if _, ok := isValues[strings.ToLower(value)]; !ok {
+			return Filter{}, fmt.Errorf("invalid filter on %q: is expects null, true, false or unknown", column)
+		}
+		filter.Value = value
+	default:
+		filter.Value = unquote(value)
+	}
+
+	return filter, nil
+}
+
+// parseLogic parses the value of an or, and, not.or or not.and parameter,
+// e.g. (a.eq.1,b.gt.2), into a group
+func parseLogic(key, value string) (Group, error) {
+	group := Group{}
+	if strings.HasPrefix(key, "not.") {
+		group.Negated = true
+		key = key[len("not."):]
+	}
+	group.Or = key == "or"
+
+	if !strings.HasPrefix(value, "(") || !strings.HasSuffix(value, ")") {
+		return Group{}, fmt.Errorf("invalid %s filter: expected a list like (a.eq.1,b.gt.2)", key)
+	}
+	if err := parseLogicItems(&group, value[1:len(value)-1]); err != nil {
+		return Group{}, fmt.Errorf("invalid %s filter: %v", key, err)
+	}
+	return group, nil
+}
+
+// parseLogicItems adds the comma separated conditions of a logic tree to
+// group. Items are col.op.value filters or nested and(...)/or(...) groups,
+// either of them optionally prefixed with not.
+func parseLogicItems(group *Group, list string) error {
+	items, err := splitList(list)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("empty condition list")
+	}
+
+	for _, item := range items {
+		negated := strings.HasPrefix(item, "not.")
+		rest := strings.TrimPrefix(item, "not.")
+
+		// Nested group
+		if name := logicName(rest); name != "" {
+			nested := Group{Or: name == "or", Negated: negated}
+			if !strings.HasSuffix(rest, ")") {
+				return fmt.Errorf("unbalanced parentheses in %q", item)
+			}
+			if err := parseLogicItems(&nested, rest[len(name)+1:len(rest)-1]); err != nil {
+				return err
+			}
+			group.Groups = append(group.Groups, nested)
+			continue
+		}
+
+		i := strings.Index(rest, ".")
+		if i <= 0 {
+			return fmt.Errorf("invalid condition %q, expected column.operator.value", item)
+		}
+		filter, err := parsePostgRESTFilter(rest[:i], rest[i+1:])
+		if err != nil {
+			return err
+		}
+		// not.col.op.value and col.not.op.value both negate the filter
+		filter.Negated = filter.Negated != negated
+		group.Filters = append(group.Filters, filter)
+	}
+
+	return nil
+}
+
+// logicName returns "and" or "or" when item starts a nested group
+func logicName(item string) string {
+	for _, name := range []string{"and", "or"} {
+		if strings.HasPrefix(item, name+"(") {
+			return name
+		}
+	}
+	return ""
+}
+
+// parseOrderList parses order=col.desc.nullslast,col2 into sort orders
+func parseOrderList(value string) ([]Order, error) {
+	var orders []Order
+	for _, term := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(term), ".")
+		if parts[0] == "" {
+			return nil, fmt.Errorf("invalid order %q", value)
+		}
+
+		order := Order{Column: parts[0]}
+		for _, modifier := range parts[1:] {
+			switch modifier {
+			case "asc":
+				order.Descending = false
+			case "desc":
+				order.Descending = true
+			case "nullsfirst":
+				order.Nulls = NullsFirst
+			case "nullslast":
+				order.Nulls = NullsLast
+			default:
+				return nil, fmt.Errorf("invalid order modifier %q, expected asc, desc, nullsfirst or nullslast", modifier)
+			}
+		}
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
+
+// parseSelect parses select=col1,alias:col2,col3::text,col4->>key,table(...)
+// into the selected fields and embedded resources
+func parseSelect(value string) ([]Field, []*Embed, error) {
+	fields, embeds, err := parseSelectList(value, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid select %q: %v", value, err)
+	}
+	return fields, embeds, nil
+}
+
+// parseSelectList parses a select list at the given embedding depth
+func parseSelectList(value string, depth int) ([]Field, []*Embed, error) {
+	if depth > MaxDepth {
+		return nil, nil, fmt.Errorf("embedding is nested deeper than %d levels", MaxDepth)
+	}
+
+	items, err := splitList(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(items) == 0 {
+		return nil, nil, fmt.Errorf("empty select list")
+	}
+
+	var fields []Field
+	var embeds []*Embed
+	for _, item := range items {
+		if strings.Contains(item, "(") {
+			embed, err := parseEmbed(item, depth)
+			if err != nil {
+				return nil, nil, err
+			}
+			embeds = append(embeds, embed)
+			continue
+		}
+
+		field, err := parseField(item)
+		if err != nil {
+			return nil, nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, embeds, nil
+}
+
+// splitList splits a comma separated list at the top level, keeping commas
+// inside parentheses and double quotes
+func splitList(list string) ([]string, error) {
+	var items []string
+	depth, quoted, start := 0, false, 0
+
+	for i := 0; i < len(list); i++ {
+		switch ch := list[i]; {
+		case ch == '\\' && quoted:
+			i++
+		case ch == '"':
+			quoted = !quoted
+		case quoted:
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses in %q", list)
+			}
+		case ch == ',' && depth == 0:
+			items = append(items, strings.TrimSpace(list[start:i]))
+			start = i + 1
+		}
+	}
+	if depth != 0 || quoted {
+		return nil, fmt.Errorf("unbalanced parentheses or quotes in %q", list)
+	}
+
+	if last := strings.TrimSpace(list[start:]); last != "" || len(items) > 0 {
+		items = append(items, last)
+	}
+	return items, nil
+}
+
+// unquote removes the double quotes PostgREST clients put around values
+// containing reserved characters
+func unquote(value string) string {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return value
+	}
+	return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(value[1 : len(value)-1])
+}
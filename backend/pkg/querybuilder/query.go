@@ -0,0 +1,132 @@
+// Package querybuilder parses the filter, sorting and pagination parameters
+// of read requests into a Query and renders it as SQL. Every read path uses
+// it, so all of them accept the same operators.
+package querybuilder
+
+import (
+	"fmt"
+)
+
+// Pagination defaults and bounds
+const (
+	DefaultLimit = 10
+	MaxLimit     = 100
+
+	// QueryParamsLimit is the default limit of QueryParamsMiddleware,
+	// which has always returned 25 rows
+	QueryParamsLimit = 25
+)
+
+// Operator is a comparison applied by a filter
+type Operator string
+
+// Supported operators
+const (
+	OpEq    Operator = "eq"    // column = value
+	OpNeq   Operator = "neq"   // column <> value
+	OpGt    Operator = "gt"    // column > value
+	OpGte   Operator = "gte"   // column >= value
+	OpLt    Operator = "lt"    // column < value
+	OpLte   Operator = "lte"   // column <= value
+	OpLike  Operator = "like"  // column LIKE pattern
+	OpILike Operator = "ilike" // column ILIKE pattern
+	OpIn    Operator = "in"    // column IN (values)
+	OpIs    Operator = "is"    // column IS NULL, TRUE, FALSE or UNKNOWN
+)
+
+// operators maps operator names to operators, including aliases
+var operators = map[string]Operator{
+	"eq":    OpEq,
+	"neq":   OpNeq,
+	"ne":    OpNeq,
+	"gt":    OpGt,
+	"gte":   OpGte,
+	"lt":    OpLt,
+	"lte":   OpLte,
+	"like":  OpLike,
+	"ilike": OpILike,
+	"in":    OpIn,
+	"is":    OpIs,
+}
+
+// ParseOperator returns the operator with the given name or alias
+func ParseOperator(name string) (Operator, error) {
+	op, ok := operators[name]
+	if !ok {
+		return "", fmt.Errorf("unknown operator %q", name)
+	}
+	return op, nil
+}
+
+// Filter compares a column with a value
+type Filter struct {
+	Column   string
+	Operator Operator
+	Negated  bool
+	// Value is the operand of every operator except in
+	Value string
+	// Values are the operands of in
+	Values []string
+}
+
+// Group combines filters and nested groups with AND, or with OR when Or is
+// set
+type Group struct {
+	Or      bool
+	Negated bool
+	Filters []Filter
+	Groups  []Group
+}
+
+// Placement of NULLs in a sort order
+const (
+	NullsFirst = "first"
+	NullsLast  = "last"
+)
+
+// Order sorts by a column
+type Order struct {
+	Column     string
+	Descending bool
+	// Nulls is NullsFirst, NullsLast or empty for the PostgreSQL default
+	Nulls string
+}
+
+// Query is a parsed read request. Filters and groups are combined with AND.
+// Embedded resources carry a Query of their own.
+type Query struct {
+	// Select lists the fields to return, all columns when empty
+	Select []Field
+	// Embeds lists the related tables embedded in each row
+	Embeds  []*Embed
+	Filters []Filter
+	Groups  []Group
+	Order   []Order
+	Limit   int
+	Offset  int
+	// Search is matched against the text of the whole row
+	Search string
+}
+
+// HasConditions reports whether the query filters rows
+func (q *Query) HasConditions() bool {
+	return len(q.Filters) > 0 || len(q.Groups) > 0 || q.Search != ""
+}
+
+// Page returns the 1-based page number of the query's offset
+func (q *Query) Page() int {
+	if q.Limit <= 0 {
+		return 1
+	}
+	return q.Offset/q.Limit + 1
+}
+
+// Args collects the parameters of a statement and hands out their
+// placeholders, so conditions rendered separately can share one statement
+type Args []interface{}
+
+// Add appends a parameter and returns its placeholder
+func (a *Args) Add(value interface{}) string {
+	*a = append(*a, value)
+	return fmt.Sprintf("$%d", len(*a))
+}
@@ -0,0 +1,271 @@
+package querybuilder
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Bounds on filter expressions, so a single request cannot produce an
+// arbitrarily large statement
+const (
+	MaxDepth      = 8
+	MaxConditions = 100
+	// MaxValues bounds the values all filters bind together, leaving room
+	// below PostgreSQL's limit of 65535 parameters per statement for the
+	// rest of the query
+	MaxValues = 10000
+)
+
+// Condition is a JSON filter expression. Exactly one form must be set: a
+// comparison (column, op and value), an and or or list, or a not.
+type Condition struct {
+	Column string      `json:"column,omitempty"`
+	Op     string      `json:"op,omitempty"`
+	Value  interface{} `json:"value,omitempty"`
+	And    []Condition `json:"and,omitempty"`
+	Or     []Condition `json:"or,omitempty"`
+	Not    *Condition  `json:"not,omitempty"`
+}
+
+// SearchRequest is the JSON form of a read request
+type SearchRequest struct {
+	// Select and Order use the same syntax as the select and order
+	// parameters
+	Select string     `json:"select,omitempty"`
+	Filter *Condition `json:"filter,omitempty"`
+	Order  string     `json:"order,omitempty"`
+	Limit  int        `json:"limit,omitempty"`
+	Offset int        `json:"offset,omitempty"`
+	Search string     `json:"q,omitempty"`
+}
+
+// ParseJSON parses a SearchRequest body into a Query
+func ParseJSON(body []byte) (*Query, error) {
+	var req SearchRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("invalid search request: %v", err)
+	}
+	return req.Query()
+}
+
+// Query converts the request into a Query
+func (r *SearchRequest) Query() (*Query, error) {
+	q := &Query{Limit: DefaultLimit, Search: r.Search}
+
+	if r.Limit > 0 {
+		q.Limit = r.Limit
+	}
+	if q.Limit > MaxLimit {
+		q.Limit = MaxLimit
+	}
+	if r.Offset > 0 {
+		q.Offset = r.Offset
+	}
+
+	if r.Select != "" && r.Select != "*" {
+		fields, embeds, err := parseSelect(r.Select)
+		if err != nil {
+			return nil, err
+		}
+		q.Select, q.Embeds = fields, embeds
+	}
+	if r.Order != "" {
+		orders, err := parseOrderList(r.Order)
+		if err != nil {
+			return nil, err
+		}
+		q.Order = orders
+	}
+
+	if r.Filter != nil {
+		root := Group{}
+		if err := addCondition(&root, *r.Filter, 0); err != nil {
+			return nil, err
+		}
+		q.Filters, q.Groups = root.Filters, root.Groups
+	}
+
+	if err := q.checkBounds(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// addCondition adds a JSON condition to parent, as a filter or a group
+func addCondition(parent *Group, cond Condition, depth int) error {
+	if depth > MaxDepth {
+		return fmt.Errorf("filter is nested deeper than %d levels", MaxDepth)
+	}
+
+	forms := 0
+	for _, set := range []bool{cond.Column != "" || cond.Op != "", cond.And != nil, cond.Or != nil, cond.Not != nil} {
+		if set {
+			forms++
+		}
+	}
+	if forms != 1 {
+		return fmt.Errorf("each condition needs exactly one of column/op/value, and, or, not")
+	}
+
+	switch {
+	case cond.Not != nil:
+		inner := Group{}
+		if err := addCondition(&inner, *cond.Not, depth+1); err != nil {
+			return err
+		}
+		// Negate the single filter or group the inner condition produced
+		if len(inner.Filters) == 1 {
+			filter := inner.Filters[0]
+			filter.Negated = !filter.Negated
+			parent.Filters = append(parent.Filters, filter)
+		} else {
+			group := inner.Groups[0]
+			group.Negated = !group.Negated
+			parent.Groups = append(parent.Groups, group)
+		}
+
+	case cond.And != nil || cond.Or != nil:
+		items := cond.And
+		group := Group{}
+		if cond.Or != nil {
+			items, group.Or = cond.Or, true
+		}
+		if len(items) == 0 {
+			return fmt.Errorf("and/or conditions need at least one item")
+		}
+		for _, item := range items {
+			if err := addCondition(&group, item, depth+1); err != nil {
+				return err
+			}
+		}
+		parent.Groups = append(parent.Groups, group)
+
+	default:
+		filter, err := jsonFilter(cond)
+		if err != nil {
+			return err
+		}
+		parent.Filters = append(parent.Filters, filter)
+	}
+
+	return nil
+}
+
+// jsonFilter converts a JSON comparison into a filter. Like patterns use
+// the SQL wildcards % and _ as they are.
+func jsonFilter(cond Condition) (Filter, error) {
+	if cond.Column == "" {
+		return Filter{}, fmt.Errorf("condition on op %q has no column", cond.Op)
+	}
+	op, err := ParseOperator(cond.Op)
+	if err != nil {
+		return Filter{}, fmt.Errorf("invalid condition on %q: %v", cond.Column, err)
+	}
+	filter := Filter{Column: cond.Column, Operator: op}
+
+	switch op {
+	case OpIn:
+		values, ok := cond.Value.([]interface{})
+		if !ok || len(values) == 0 {
+			return Filter{}, fmt.Errorf("invalid condition on %q: in expects a non-empty array", cond.Column)
+		}
+		for _, value := range values {
+			filter.Values = append(filter.Values, jsonScalar(value))
+		}
+	case OpIs:
+		value := "null"
+		if cond.Value != nil {
+			value = strings.ToLower(fmt.Sprint(cond.Value))
+		}
+		if _, ok := isValues[value]; !ok {
+			return Filter{}, fmt.Errorf("invalid condition on %q: is expects null, true, false or unknown", cond.Column)
+		}
+		filter.Value = value
+	default:
+		switch cond.Value.(type) {
+		case nil:
+			return Filter{}, fmt.Errorf("invalid condition on %q: %s needs a value, use is for null", cond.Column, op)
+		case []interface{}, map[string]interface{}:
+			return Filter{}, fmt.Errorf("invalid condition on %q: %s expects a single value", cond.Column, op)
+		}
+		filter.Value = jsonScalar(cond.Value)
+	}
+
+	return filter, nil
+}
+
+// jsonScalar formats a decoded JSON scalar as a parameter value. Numbers
+// keep their JSON form instead of fmt's float formatting.
+func jsonScalar(value interface{}) string {
+	if number, ok := value.(float64); ok {
+		data, _ := json.Marshal(number)
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
+
+// checkBounds enforces MaxDepth, MaxConditions and MaxValues, counting the
+// filters of embedded resources as well
+func (q *Query) checkBounds() error {
+	var count filterCount
+	if err := q.count(&count); err != nil {
+		return err
+	}
+	if count.conditions > MaxConditions {
+		return fmt.Errorf("filter has more than %d conditions", MaxConditions)
+	}
+	if count.values > MaxValues {
+		return fmt.Errorf("filter has more than %d values", MaxValues)
+	}
+	return nil
+}
+
+// filterCount tallies the conditions of a query and the values they bind
+type filterCount struct {
+	conditions int
+	values     int
+}
+
+// add counts filters, each in value binding a parameter of its own
+func (c *filterCount) add(filters []Filter) {
+	for _, filter := range filters {
+		c.conditions++
+		if filter.Operator == OpIn {
+			c.values += len(filter.Values)
+		} else {
+			c.values++
+		}
+	}
+}
+
+// count adds the filters of the query and its embeds to count
+func (q *Query) count(count *filterCount) error {
+	count.add(q.Filters)
+	for _, group := range q.Groups {
+		if err := group.count(count, 1); err != nil {
+			return err
+		}
+	}
+	for _, embed := range q.Embeds {
+		if err := embed.count(count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// count adds the filters of the group to count, checking its depth
+func (g Group) count(count *filterCount, depth int) error {
+	if depth > MaxDepth {
+		return fmt.Errorf("filter is nested deeper than %d levels", MaxDepth)
+	}
+
+	count.add(g.Filters)
+	for _, group := range g.Groups {
+		if err := group.count(count, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
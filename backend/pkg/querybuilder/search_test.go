@@ -0,0 +1,44 @@
+package querybuilder
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// inList returns a JSON in condition on column with n values
+func inList(column string, n int) string {
+	values := make([]string, n)
+	for i := range values {
+		values[i] = strconv.Itoa(i)
+	}
+	return `{"column": "` + column + `", "op": "in", "value": [` + strings.Join(values, ",") + `]}`
+}
+
+func TestParseJSONBoundsValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		lists   int
+		size    int
+		wantErr bool
+	}{
+		{"one list at the limit", 1, MaxValues, false},
+		{"one list over the limit", 1, MaxValues + 1, true},
+		{"many lists over the limit together", 11, MaxValues / 10, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conditions := make([]string, tt.lists)
+			for i := range conditions {
+				conditions[i] = inList("c"+strconv.Itoa(i), tt.size)
+			}
+			body := `{"filter": {"and": [` + strings.Join(conditions, ",") + `]}}`
+
+			_, err := ParseJSON([]byte(body))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
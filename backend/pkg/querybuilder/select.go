@@ -0,0 +1,145 @@
+package querybuilder
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// castPattern matches the type names accepted by casts, such as text,
+// double precision or int[]
+var castPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*( [A-Za-z_][A-Za-z0-9_]*)*(\[\])?$`)
+
+// Field is a selected column, written alias:column->key->>key::type in the
+// select parameter. Only Column is required.
+type Field struct {
+	// Column is a column name, or * for all columns
+	Column string
+	// Path lists the JSON keys or array indexes extracted from the column
+	Path []string
+	// Text extracts the last path element as text (->>) instead of JSON (->)
+	Text  bool
+	Cast  string
+	Alias string
+}
+
+// Name returns the name of the field in the result: its alias, the last
+// JSON path element or the column
+func (f Field) Name() string {
+	switch {
+	case f.Alias != "":
+		return f.Alias
+	case len(f.Path) > 0:
+		return f.Path[len(f.Path)-1]
+	default:
+		return f.Column
+	}
+}
+
+// ColumnNames returns the table columns the select list reads, excluding *
+func (q *Query) ColumnNames() []string {
+	var names []string
+	for _, field := range q.Select {
+		if field.Column != "*" {
+			names = append(names, field.Column)
+		}
+	}
+	return names
+}
+
+// parseField parses one element of the select parameter
+func parseField(value string) (Field, error) {
+	var field Field
+
+	// A single colon separates the alias, two start a cast
+	if i := strings.Index(value, ":"); i >= 0 && !strings.HasPrefix(value[i:], "::") {
+		field.Alias, value = strings.TrimSpace(value[:i]), strings.TrimSpace(value[i+1:])
+		if field.Alias == "" {
+			return Field{}, fmt.Errorf("empty alias")
+		}
+	}
+
+	if i := strings.LastIndex(value, "::"); i >= 0 {
+		field.Cast, value = strings.TrimSpace(value[i+2:]), strings.TrimSpace(value[:i])
+		if !castPattern.MatchString(field.Cast) {
+			return Field{}, fmt.Errorf("invalid cast %q", field.Cast)
+		}
+	}
+
+	// Split the column from its JSON path; only the last step may use ->>
+	parts := strings.Split(value, "->")
+	field.Column = strings.TrimSpace(parts[0])
+	for i, part := range parts[1:] {
+		if strings.HasPrefix(part, ">") {
+			if i != len(parts)-2 {
+				return Field{}, fmt.Errorf("->> must be the last step of a JSON path")
+			}
+			part, field.Text = part[1:], true
+		}
+		if part == "" {
+			return Field{}, fmt.Errorf("empty JSON path element")
+		}
+		field.Path = append(field.Path, part)
+	}
+
+	if field.Column == "" {
+		return Field{}, fmt.Errorf("empty column")
+	}
+	if field.Column == "*" && (len(field.Path) > 0 || field.Cast != "" || field.Alias != "") {
+		return Field{}, fmt.Errorf("* cannot have an alias, cast or JSON path")
+	}
+	return field, nil
+}
+
+// sql renders the field for the select list or a RETURNING clause
+func (f Field) sql(table string) string {
+	if f.Column == "*" {
+		return pgx.Identifier{table}.Sanitize() + ".*"
+	}
+
+	expression := pgx.Identifier{table, f.Column}.Sanitize()
+	for i, key := range f.Path {
+		operator := "->"
+		if f.Text && i == len(f.Path)-1 {
+			operator = "->>"
+		}
+		expression += operator + jsonKey(key)
+	}
+
+	if f.Cast != "" {
+		if len(f.Path) > 0 {
+			expression = "(" + expression + ")"
+		}
+		expression += "::" + f.Cast
+	}
+
+	if f.Alias == "" && len(f.Path) == 0 && f.Cast == "" {
+		return expression
+	}
+	return expression + " AS " + pgx.Identifier{f.Name()}.Sanitize()
+}
+
+// jsonKey renders a JSON path element, an array index for integers and a
+// string literal otherwise
+func jsonKey(key string) string {
+	if isInteger(key) {
+		return key
+	}
+	return "'" + strings.ReplaceAll(key, "'", "''") + "'"
+}
+
+// isInteger reports whether s is a possibly negative decimal integer
+func isInteger(s string) bool {
+	digits := strings.TrimPrefix(s, "-")
+	if digits == "" {
+		return false
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
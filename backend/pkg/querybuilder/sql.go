@@ -0,0 +1,153 @@
+package querybuilder
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// isValues maps the operands of is to their SQL keywords
+var isValues = map[string]string{
+	"null":    "NULL",
+	"true":    "TRUE",
+	"false":   "FALSE",
+	"unknown": "UNKNOWN",
+}
+
+// comparisons maps the binary operators to their SQL
+var comparisons = map[Operator]string{
+	OpEq:    "=",
+	OpNeq:   "<>",
+	OpGt:    ">",
+	OpGte:   ">=",
+	OpLt:    "<",
+	OpLte:   "<=",
+	OpLike:  "LIKE",
+	OpILike: "ILIKE",
+}
+
+// Columns renders the select list, * when no columns were selected. It
+// also serves as a RETURNING list.
+func (q *Query) Columns(table string) string {
+	if len(q.Select) == 0 {
+		return "*"
+	}
+
+	columns := make([]string, len(q.Select))
+	for i, field := range q.Select {
+		columns[i] = field.sql(table)
+	}
+	return strings.Join(columns, ", ")
+}
+
+// Where renders the filters, groups and search as a condition without the
+// WHERE keyword, or an empty string when there is nothing to filter.
+// Columns are qualified with table, which is a table name or alias, and
+// values are added to args.
+func (q *Query) Where(table string, args *Args) string {
+	var conditions []string
+
+	if q.Search != "" {
+		conditions = append(conditions, fmt.Sprintf(
+			"to_tsvector('english', to_jsonb(%s.*)::text) @@ plainto_tsquery('english', %s)",
+			pgx.Identifier{table}.Sanitize(), args.Add(q.Search),
+		))
+	}
+
+	for _, filter := range q.Filters {
+		conditions = append(conditions, filter.sql(table, args))
+	}
+	for _, group := range q.Groups {
+		conditions = append(conditions, group.sql(table, args))
+	}
+
+	return strings.Join(conditions, " AND ")
+}
+
+// sql renders a group in parentheses
+func (g Group) sql(table string, args *Args) string {
+	var conditions []string
+	for _, filter := range g.Filters {
+		conditions = append(conditions, filter.sql(table, args))
+	}
+	for _, group := range g.Groups {
+		conditions = append(conditions, group.sql(table, args))
+	}
+
+	operator := " AND "
+	if g.Or {
+		operator = " OR "
+	}
+	condition := "(" + strings.Join(conditions, operator) + ")"
+	if g.Negated {
+		condition = "NOT " + condition
+	}
+	return condition
+}
+
+// sql renders a single filter
+func (f Filter) sql(table string, args *Args) string {
+	column := pgx.Identifier{table, f.Column}.Sanitize()
+
+	var condition string
+	switch f.Operator {
+	case OpIn:
+		// Nothing is in an empty list, and IN () is not valid SQL
+		if len(f.Values) == 0 {
+			condition = "FALSE"
+			break
+		}
+		placeholders := make([]string, len(f.Values))
+		for i, value := range f.Values {
+			placeholders[i] = args.Add(value)
+		}
+		condition = fmt.Sprintf("%s IN (%s)", column, strings.Join(placeholders, ", "))
+	case OpIs:
+		condition = fmt.Sprintf("%s IS %s", column, isValues[strings.ToLower(f.Value)])
+	default:
+		condition = fmt.Sprintf("%s %s %s", column, comparisons[f.Operator], args.Add(f.Value))
+	}
+
+	if f.Negated {
+		condition = "NOT (" + condition + ")"
+	}
+	return condition
+}
+
+// OrderBy renders the ORDER BY clause, or an empty string without any
+// sort order
+func (q *Query) OrderBy(table string) string {
+	if len(q.Order) == 0 {
+		return ""
+	}
+
+	terms := make([]string, len(q.Order))
+	for i, order := range q.Order {
+		direction := "ASC"
+		if order.Descending {
+			direction = "DESC"
+		}
+		terms[i] = pgx.Identifier{table, order.Column}.Sanitize() + " " + direction
+		switch order.Nulls {
+		case NullsFirst:
+			terms[i] += " NULLS FIRST"
+		case NullsLast:
+			terms[i] += " NULLS LAST"
+		}
+	}
+
+	return "ORDER BY " + strings.Join(terms, ", ")
+}
+
+// Pagination renders the LIMIT and OFFSET clauses
+func (q *Query) Pagination() string {
+	clause := ""
+	if q.Limit > 0 {
+		clause = fmt.Sprintf("LIMIT %d", q.Limit)
+	}
+	if q.Offset > 0 {
+		clause = strings.TrimSpace(fmt.Sprintf("%s OFFSET %d", clause, q.Offset))
+	}
+	return clause
+}
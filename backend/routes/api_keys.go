@@ -0,0 +1,429 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackson/supabase-go/db"
+	"github.com/jackson/supabase-go/middleware"
+)
+
+// defaultRotationOverlap is how long a rotated key keeps working when the
+// request does not say otherwise
+const defaultRotationOverlap = 24 * time.Hour
+
+// apiKeyIDPattern matches the UUIDs that identify api_keys rows. Other IDs
+// cannot exist and are reported as not found without a query.
+var apiKeyIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// APIKeyInfo describes an API key without its secret
+type APIKeyInfo struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Roles      []string   `json:"roles"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	CreatedBy  string     `json:"created_by"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Revoked    bool       `json:"revoked"`
+	ReplacedBy string     `json:"replaced_by,omitempty"`
+}
+
+// IssuedAPIKey is returned once when a key is created or rotated. Key is
+// the only copy of the secret, the database only stores its hash.
+type IssuedAPIKey struct {
+	APIKeyInfo
+	Key string `json:"key"`
+}
+
+// APIKeyRequest represents a request to create or update an API key
+type APIKeyRequest struct {
+	Name      string     `json:"name"`
+	Roles     []string   `json:"roles"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// RotateAPIKeyRequest represents a request to rotate an API key. Overlap is
+// a duration such as "1h" during which the old key keeps working.
+type RotateAPIKeyRequest struct {
+	Overlap   string     `json:"overlap"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// apiKeyColumns is the column list used when reading api_keys rows
+const apiKeyColumns = `
+	id, name, prefix, roles, expires_at, created_at, COALESCE(created_by, ''),
+	last_used_at, revoked, COALESCE(replaced_by::text, '')
+`
+
+// GetAPIKeys returns all API keys, including revoked ones
+func GetAPIKeys(database *db.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := context.Background()
+
+		keys, err := ListAPIKeys(ctx, database)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to query API keys: %v", err),
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"keys": keys,
+		})
+	}
+}
+
+// GetAPIKey returns a single API key by ID
+func GetAPIKey(database *db.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := context.Background()
+		keyID := c.Params("id")
+
+		var key APIKeyInfo
+		if err := getAPIKey(ctx, database, keyID, &key); err != nil {
+			if err == pgx.ErrNoRows {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": fmt.Sprintf("API key with ID %s not found", keyID),
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to get API key: %v", err),
+			})
+		}
+
+		return c.JSON(key)
+	}
+}
+
+// CreateAPIKey issues a new API key. The secret is only part of this response.
+func CreateAPIKey(database *db.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := context.Background()
+
+		var req APIKeyRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid request body: %v", err),
+			})
+		}
+		if err := validateAPIKeyRequest(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		userID, _ := c.Locals("userId").(string)
+		issued, err := IssueAPIKey(ctx, database, req, userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to create API key: %v", err),
+			})
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(issued)
+	}
+}
+
+// UpdateAPIKey changes the name, roles or expiry of an API key. Fields left
+// empty in the request are kept.
+func UpdateAPIKey(database *db.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := context.Background()
+		keyID := c.Params("id")
+
+		var req APIKeyRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid request body: %v", err),
+			})
+		}
+		if req.Roles != nil && len(req.Roles) == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "roles cannot be empty",
+			})
+		}
+		if err := validateExpiry(req.ExpiresAt); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		var key APIKeyInfo
+		if err := updateAPIKey(ctx, database, keyID, req, &key); err != nil {
+			if err == pgx.ErrNoRows {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": fmt.Sprintf("API key with ID %s not found", keyID),
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to update API key: %v", err),
+			})
+		}
+
+		return c.JSON(key)
+	}
+}
+
+// RotateAPIKeyHandler issues a replacement for an API key. The old key
+// keeps working until the overlap window has passed.
+func RotateAPIKeyHandler(database *db.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := context.Background()
+		keyID := c.Params("id")
+
+		var req RotateAPIKeyRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": fmt.Sprintf("Invalid request body: %v", err),
+				})
+			}
+		}
+
+		overlap := defaultRotationOverlap
+		if req.Overlap != "" {
+			var err error
+			overlap, err = time.ParseDuration(req.Overlap)
+			if err != nil || overlap < 0 {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "overlap must be a non-negative duration such as '1h'",
+				})
+			}
+		}
+		if err := validateExpiry(req.ExpiresAt); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		userID, _ := c.Locals("userId").(string)
+		issued, err := RotateAPIKey(ctx, database, keyID, overlap, req.ExpiresAt, userID)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": fmt.Sprintf("API key with ID %s not found", keyID),
+				})
+			}
+			if err == middleware.ErrAPIKeyRevoked {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Revoked API keys cannot be rotated",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to rotate API key: %v", err),
+			})
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(issued)
+	}
+}
+
+// RevokeAPIKeyHandler revokes an API key. The row is kept for listing.
+func RevokeAPIKeyHandler(database *db.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := context.Background()
+		keyID := c.Params("id")
+
+		if err := RevokeAPIKey(ctx, database, keyID); err != nil {
+			if err == pgx.ErrNoRows {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": fmt.Sprintf("API key with ID %s not found", keyID),
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to revoke API key: %v", err),
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "API key revoked successfully",
+		})
+	}
+}
+
+// ListAPIKeys returns all API keys, newest first
+func ListAPIKeys(ctx context.Context, database *db.DB) ([]APIKeyInfo, error) {
+	rows, err := database.Query(ctx, "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY created_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKeyInfo{}
+	for rows.Next() {
+		var key APIKeyInfo
+		if err := scanAPIKey(rows, &key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// IssueAPIKey generates a key, stores its hash and returns the secret
+func IssueAPIKey(ctx context.Context, database *db.DB, req APIKeyRequest, createdBy string) (IssuedAPIKey, error) {
+	if err := validateAPIKeyRequest(req); err != nil {
+		return IssuedAPIKey{}, err
+	}
+	return issueAPIKey(ctx, database, req, createdBy)
+}
+
+// RotateAPIKey issues a key with the same name and roles as an existing
+// one and lets the old key expire after overlap
+func RotateAPIKey(ctx context.Context, database *db.DB, keyID string, overlap time.Duration, expiresAt *time.Time, createdBy string) (IssuedAPIKey, error) {
+	var issued IssuedAPIKey
+	if !apiKeyIDPattern.MatchString(keyID) {
+		return issued, pgx.ErrNoRows
+	}
+
+	tx, err := database.Begin(ctx)
+	if err != nil {
+		return issued, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var old APIKeyInfo
+	err = scanAPIKey(tx.QueryRow(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE id = $1 FOR UPDATE", keyID), &old)
+	if err != nil {
+		return issued, err
+	}
+	if old.Revoked {
+		return issued, middleware.ErrAPIKeyRevoked
+	}
+
+	req := APIKeyRequest{Name: old.Name, Roles: old.Roles, ExpiresAt: expiresAt}
+	if err := validateAPIKeyRequest(req); err != nil {
+		return issued, err
+	}
+	issued, err = issueAPIKey(ctx, tx, req, createdBy)
+	if err != nil {
+		return issued, err
+	}
+
+	// The old key never outlives its original expiry
+	query := `
+		UPDATE api_keys
+		SET
+			replaced_by = $2,
+			expires_at = LEAST(COALESCE(expires_at, 'infinity'), NOW() + $3::interval)
+		WHERE id = $1
+	`
+	if _, err := tx.Exec(ctx, query, keyID, issued.ID, overlap.String()); err != nil {
+		return issued, fmt.Errorf("failed to expire old key: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return issued, fmt.Errorf("failed to commit rotation: %w", err)
+	}
+
+	return issued, nil
+}
+
+// RevokeAPIKey marks an API key as revoked
+func RevokeAPIKey(ctx context.Context, database *db.DB, keyID string) error {
+	if !apiKeyIDPattern.MatchString(keyID) {
+		return pgx.ErrNoRows
+	}
+	var id string
+	return database.QueryRow(ctx, "UPDATE api_keys SET revoked = TRUE WHERE id = $1 RETURNING id", keyID).Scan(&id)
+}
+
+// issueAPIKey inserts a new key using any database handle that can run queries
+func issueAPIKey(ctx context.Context, q db.Querier, req APIKeyRequest, createdBy string) (IssuedAPIKey, error) {
+	var issued IssuedAPIKey
+
+	rawKey, prefix, err := middleware.GenerateAPIKey()
+	if err != nil {
+		return issued, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	query := `
+		INSERT INTO api_keys (name, hashed_key, prefix, roles, expires_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+		RETURNING ` + apiKeyColumns
+
+	row := q.QueryRow(ctx, query, req.Name, middleware.HashAPIKey(rawKey), prefix, rolesArrayToJson(req.Roles), req.ExpiresAt, createdBy)
+	if err := scanAPIKey(row, &issued.APIKeyInfo); err != nil {
+		return issued, err
+	}
+	issued.Key = rawKey
+
+	return issued, nil
+}
+
+// getAPIKey reads a single API key by ID
+func getAPIKey(ctx context.Context, database *db.DB, keyID string, key *APIKeyInfo) error {
+	if !apiKeyIDPattern.MatchString(keyID) {
+		return pgx.ErrNoRows
+	}
+	return scanAPIKey(database.QueryRow(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE id = $1", keyID), key)
+}
+
+// updateAPIKey applies the non-empty fields of req to an API key
+func updateAPIKey(ctx context.Context, database *db.DB, keyID string, req APIKeyRequest, key *APIKeyInfo) error {
+	if !apiKeyIDPattern.MatchString(keyID) {
+		return pgx.ErrNoRows
+	}
+
+	var roles interface{}
+	if req.Roles != nil {
+		roles = rolesArrayToJson(req.Roles)
+	}
+
+	query := `
+		UPDATE api_keys
+		SET
+			name = COALESCE(NULLIF($2, ''), name),
+			roles = COALESCE($3, roles),
+			expires_at = COALESCE($4, expires_at)
+		WHERE id = $1
+		RETURNING ` + apiKeyColumns
+
+	return scanAPIKey(database.QueryRow(ctx, query, keyID, req.Name, roles, req.ExpiresAt), key)
+}
+
+// scanAPIKey scans a row selected with apiKeyColumns into key
+func scanAPIKey(row pgx.Row, key *APIKeyInfo) error {
+	var rolesJson string
+	err := row.Scan(
+		&key.ID,
+		&key.Name,
+		&key.Prefix,
+		&rolesJson,
+		&key.ExpiresAt,
+		&key.CreatedAt,
+		&key.CreatedBy,
+		&key.LastUsedAt,
+		&key.Revoked,
+		&key.ReplacedBy,
+	)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(rolesJson), &key.Roles)
+}
+
+// validateAPIKeyRequest checks the fields required to issue a key
+func validateAPIKeyRequest(req APIKeyRequest) error {
+	if req.Name == "" || len(req.Roles) == 0 {
+		return fmt.Errorf("name and roles are required")
+	}
+	return validateExpiry(req.ExpiresAt)
+}
+
+// validateExpiry checks that an optional expiry is in the future
+func validateExpiry(expiresAt *time.Time) error {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return fmt.Errorf("expires_at must be in the future")
+	}
+	return nil
+}
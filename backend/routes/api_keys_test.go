@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAPIKeyHandlersRejectInvalidIDs(t *testing.T) {
+	app := fiber.New()
+	// Invalid IDs are answered before the database is used
+	app.Get("/api/keys/:id", GetAPIKey(nil))
+	app.Patch("/api/keys/:id", UpdateAPIKey(nil))
+	app.Post("/api/keys/:id/rotate", RotateAPIKeyHandler(nil))
+	app.Delete("/api/keys/:id", RevokeAPIKeyHandler(nil))
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"GET", "/api/keys/not-a-uuid", ""},
+		{"PATCH", "/api/keys/not-a-uuid", `{"name": "ci"}`},
+		{"POST", "/api/keys/not-a-uuid/rotate", ""},
+		{"DELETE", "/api/keys/not-a-uuid", ""},
+		{"GET", "/api/keys/1234", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+			if resp.StatusCode != fiber.StatusNotFound {
+				t.Errorf("status %d, want %d", resp.StatusCode, fiber.StatusNotFound)
+			}
+		})
+	}
+}
+
+func TestValidateAPIKeyRequest(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		req     APIKeyRequest
+		wantErr bool
+	}{
+		{"valid", APIKeyRequest{Name: "ci", Roles: []string{"service"}}, false},
+		{"future expiry", APIKeyRequest{Name: "ci", Roles: []string{"service"}, ExpiresAt: &future}, false},
+		{"past expiry", APIKeyRequest{Name: "ci", Roles: []string{"service"}, ExpiresAt: &past}, true},
+		{"no name", APIKeyRequest{Roles: []string{"service"}}, true},
+		{"no roles", APIKeyRequest{Name: "ci"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateAPIKeyRequest(tt.req); (err != nil) != tt.wantErr {
+				t.Errorf("validateAPIKeyRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackson/supabase-go/middleware"
+)
+
+// AuthUser is the JSON shape of the authenticated user. Every field is
+// always present, with null or empty values when the credentials do not
+// provide them.
+type AuthUser struct {
+	ID          *string    `json:"id"`
+	Email       *string    `json:"email"`
+	Roles       []string   `json:"roles"`
+	PrimaryRole string     `json:"primary_role"`
+	AuthMethod  string     `json:"auth_method"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	SessionID   *string    `json:"session_id"`
+	OrgID       *string    `json:"org_id"`
+	OrgRole     *string    `json:"org_role"`
+	// ImpersonatedBy is the admin acting as this user, if any
+	ImpersonatedBy *string `json:"impersonated_by"`
+}
+
+// AuthSession describes the credentials of the current request
+type AuthSession struct {
+	SessionID  *string    `json:"session_id"`
+	UserID     *string    `json:"user_id"`
+	AuthMethod string     `json:"auth_method"`
+	Issuer     *string    `json:"issuer"`
+	IssuedAt   *time.Time `json:"issued_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	ExpiresIn  *int64     `json:"expires_in"`
+	APIKey     *APIKeyRef `json:"api_key"`
+}
+
+// APIKeyRef identifies the API key a request was authenticated with
+type APIKeyRef struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Prefix string `json:"prefix"`
+}
+
+// GetAuthUser returns the authenticated user
+func GetAuthUser() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal := currentPrincipal(c)
+
+		roles, err := middleware.ResolveRoles(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to resolve user roles: %v", err),
+			})
+		}
+
+		email, err := middleware.UserEmail(c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to get user profile: %v", err),
+			})
+		}
+
+		return c.JSON(AuthUser{
+			ID:             optionalString(principal.UserID),
+			Email:          optionalString(email),
+			Roles:          roles.Roles,
+			PrimaryRole:    roles.Primary,
+			AuthMethod:     principal.Method,
+			ExpiresAt:      principal.ExpiresAt,
+			SessionID:      optionalString(principal.SessionID),
+			OrgID:          optionalString(principal.OrgID),
+			OrgRole:        optionalString(principal.OrgRole),
+			ImpersonatedBy: impersonatorID(c),
+		})
+	}
+}
+
+// GetAuthSession returns metadata about the current session or credentials
+func GetAuthSession() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal := currentPrincipal(c)
+
+		session := AuthSession{
+			SessionID:  optionalString(principal.SessionID),
+			UserID:     optionalString(principal.UserID),
+			AuthMethod: principal.Method,
+			ExpiresAt:  principal.ExpiresAt,
+		}
+
+		if iss, ok := principal.Claims["iss"].(string); ok {
+			session.Issuer = optionalString(iss)
+		}
+		if iat, ok := principal.Claims["iat"].(float64); ok {
+			issuedAt := time.Unix(int64(iat), 0).UTC()
+			session.IssuedAt = &issuedAt
+		}
+		if principal.ExpiresAt != nil {
+			expiresIn := int64(time.Until(*principal.ExpiresAt).Seconds())
+			if expiresIn < 0 {
+				expiresIn = 0
+			}
+			session.ExpiresIn = &expiresIn
+		}
+		if key, ok := c.Locals("apiKey").(*middleware.APIKey); ok {
+			session.APIKey = &APIKeyRef{ID: key.ID, Name: key.Name, Prefix: key.Prefix}
+		}
+
+		return c.JSON(session)
+	}
+}
+
+// currentPrincipal returns the request's principal. Requests that reached
+// a handler without one are anonymous.
+func currentPrincipal(c *fiber.Ctx) *middleware.Principal {
+	if principal, ok := c.Locals("principal").(*middleware.Principal); ok {
+		return principal
+	}
+	return &middleware.Principal{Method: "anon"}
+}
+
+// impersonatorID returns the user ID of the admin impersonating the
+// current user, or nil
+func impersonatorID(c *fiber.Ctx) *string {
+	if actor := middleware.Impersonator(c); actor != nil {
+		return &actor.UserID
+	}
+	return nil
+}
+
+// optionalString returns nil for an empty string so it is encoded as null
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
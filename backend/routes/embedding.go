@@ -0,0 +1,346 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackson/supabase-go/db"
+	"github.com/jackson/supabase-go/middleware"
+	"github.com/jackson/supabase-go/pkg/querybuilder"
+)
+
+var (
+	// errUnknownRelationship is returned for embeds matching no foreign key
+	// relationship, or more than one
+	errUnknownRelationship = errors.New("cannot embed")
+	// errEmbedDenied is returned for embeds of tables the caller may not read
+	errEmbedDenied = errors.New("embedded table")
+	// errEmbedOnWrite is returned for embeds in the select list of a write
+	errEmbedOnWrite = errors.New("embedded resources can only be selected when reading rows")
+)
+
+// relationship joins a parent table to an embedded table through a single
+// column foreign key, or through a join table for many-to-many
+type relationship struct {
+	table string
+	// many embeds the rows as an array rather than a single object
+	many         bool
+	parentColumn string
+	// column matches parentColumn, on the embedded table or on through
+	column  string
+	through string
+	// throughColumn of through references targetColumn of the embedded table
+	throughColumn string
+	targetColumn  string
+	// names are the constraints, columns and join table a hint can match
+	names []string
+}
+
+// embedder resolves the embedded resources of a read through the foreign
+// keys of the schema and renders them as JSON columns
+type embedder struct {
+	ctx       context.Context
+	c         *fiber.Ctx
+	database  *db.DB
+	rlsEngine *middleware.RLSEngine
+	tenants   *middleware.TenantScope
+	args      *querybuilder.Args
+	fkeys     []ForeignKey
+	aliases   int
+}
+
+// newEmbedder returns an embedder adding its values to args
+func newEmbedder(ctx context.Context, c *fiber.Ctx, database *db.DB, rlsEngine *middleware.RLSEngine, tenants *middleware.TenantScope, args *querybuilder.Args) *embedder {
+	return &embedder{
+		ctx:       ctx,
+		c:         c,
+		database:  database,
+		rlsEngine: rlsEngine,
+		tenants:   tenants,
+		args:      args,
+	}
+}
+
+// columns renders the select list of a read on table, with its embedded
+// resources as json columns
+func (e *embedder) columns(table string, query *querybuilder.Query) (string, error) {
+	if len(query.Embeds) == 0 {
+		return selectColumns(e.ctx, e.database, table, query)
+	}
+	return e.render(table, table, query)
+}
+
+// conditions returns the conditions of the !inner embeds of a read on
+// table, which drop rows without embedded rows
+func (e *embedder) conditions(table string, query *querybuilder.Query) ([]string, error) {
+	return e.innerConditions(table, table, query)
+}
+
+// render renders the fields and embeds of query on table, qualified with
+// alias
+func (e *embedder) render(table, alias string, query *querybuilder.Query) (string, error) {
+	if err := checkColumns(e.ctx, e.database, table, query); err != nil {
+		return "", err
+	}
+
+	var columns []string
+	if len(query.Select) > 0 {
+		columns = append(columns, query.Columns(alias))
+	}
+
+	for _, embed := range query.Embeds {
+		rel, err := e.resolve(table, embed)
+		if err != nil {
+			return "", err
+		}
+		if err := e.authorize(rel); err != nil {
+			return "", err
+		}
+
+		// Aggregate the embedded rows of each parent row into JSON
+		embedAlias := e.alias()
+		inner, err := e.render(rel.table, embedAlias, &embed.Query)
+		if err != nil {
+			return "", err
+		}
+		conditions, err := e.scope(alias, embedAlias, embed, rel)
+		if err != nil {
+			return "", err
+		}
+		inner = fmt.Sprintf("SELECT %s FROM %s AS %s WHERE %s",
+			inner,
+			pgx.Identifier{rel.table}.Sanitize(),
+			pgx.Identifier{embedAlias}.Sanitize(),
+			strings.Join(conditions, " AND "))
+
+		rows := pgx.Identifier{embedAlias + "_rows"}.Sanitize()
+		var column string
+		if rel.many {
+			inner = strings.TrimSpace(fmt.Sprintf("%s %s %s", inner, embed.OrderBy(embedAlias), embed.Pagination()))
+			column = fmt.Sprintf("COALESCE((SELECT json_agg(%s) FROM (%s) AS %s), '[]'::json)", rows, inner, rows)
+		} else {
+			column = fmt.Sprintf("(SELECT row_to_json(%s) FROM (%s) AS %s)", rows, inner, rows)
+		}
+		columns = append(columns, column+" AS "+pgx.Identifier{embed.ResultName()}.Sanitize())
+	}
+
+	return strings.Join(columns, ", "), nil
+}
+
+// innerConditions returns an EXISTS condition for each !inner embed of
+// query on table, qualified with alias
+func (e *embedder) innerConditions(table, alias string, query *querybuilder.Query) ([]string, error) {
+	var conditions []string
+	for _, embed := range query.Embeds {
+		if !embed.Inner {
+			continue
+		}
+
+		rel, err := e.resolve(table, embed)
+		if err != nil {
+			return nil, err
+		}
+		embedAlias := e.alias()
+		scope, err := e.scope(alias, embedAlias, embed, rel)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 FROM %s AS %s WHERE %s)",
+			pgx.Identifier{rel.table}.Sanitize(),
+			pgx.Identifier{embedAlias}.Sanitize(),
+			strings.Join(scope, " AND ")))
+	}
+	return conditions, nil
+}
+
+// scope returns the conditions selecting the embedded rows of a parent row:
+// the join, the embed's filters, the tenant scope of the embedded and join
+// tables and its own inner embeds
+func (e *embedder) scope(parentAlias, alias string, embed *querybuilder.Embed, rel relationship) ([]string, error) {
+	parent := func(column string) string { return pgx.Identifier{parentAlias, column}.Sanitize() }
+	embedded := func(column string) string { return pgx.Identifier{alias, column}.Sanitize() }
+
+	var conditions []string
+	if rel.through == "" {
+		conditions = append(conditions, fmt.Sprintf("%s = %s", embedded(rel.column), parent(rel.parentColumn)))
+	} else {
+		through := e.alias()
+		join := fmt.Sprintf("%s = %s AND %s = %s",
+			pgx.Identifier{through, rel.throughColumn}.Sanitize(), embedded(rel.targetColumn),
+			pgx.Identifier{through, rel.column}.Sanitize(), parent(rel.parentColumn))
+
+		// The unqualified tenant column resolves to the join table here
+		condition, err := tenantCondition(e.c, e.tenants, rel.through, e.args)
+		if err != nil {
+			return nil, err
+		}
+		if condition != "" {
+			join += " AND " + condition
+		}
+
+		conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 FROM %s AS %s WHERE %s)",
+			pgx.Identifier{rel.through}.Sanitize(), pgx.Identifier{through}.Sanitize(), join))
+	}
+
+	if where := embed.Where(alias, e.args); where != "" {
+		conditions = append(conditions, where)
+	}
+
+	// Scope tenant tables to the active organization
+	condition, err := tenantCondition(e.c, e.tenants, rel.table, e.args)
+	if err != nil {
+		return nil, err
+	}
+	if condition != "" {
+		conditions = append(conditions, condition)
+	}
+
+	inner, err := e.innerConditions(rel.table, alias, &embed.Query)
+	if err != nil {
+		return nil, err
+	}
+	return append(conditions, inner...), nil
+}
+
+// resolve finds the one relationship between parent and an embed. The
+// embed names the related table, or for many-to-one a foreign key
+// constraint or column, with or without its _id suffix. One-to-many
+// relationships can also be hinted by their referencing table.column, the
+// only hint telling them apart from many-to-one on a self-referencing key.
+func (e *embedder) resolve(parent string, embed *querybuilder.Embed) (relationship, error) {
+	fkeys, err := e.foreignKeys()
+	if err != nil {
+		return relationship{}, err
+	}
+
+	var candidates []relationship
+	for _, fk := range fkeys {
+		// Many-to-one: the parent references the embedded table
+		if fk.Table == parent && (fk.RefTable == embed.Name || fk.Name == embed.Name ||
+			fk.ColumnName == embed.Name || fk.ColumnName == embed.Name+"_id") {
+			candidates = append(candidates, relationship{
+				table:        fk.RefTable,
+				parentColumn: fk.ColumnName,
+				column:       fk.RefColumnName,
+				names:        []string{fk.Name, fk.ColumnName},
+			})
+		}
+
+		// One-to-many: the embedded table references the parent
+		if fk.RefTable == parent && (fk.Table == embed.Name || fk.Name == embed.Name) {
+			names := []string{fk.Table + "." + fk.ColumnName}
+			if fk.Table != fk.RefTable {
+				names = append([]string{fk.Name, fk.ColumnName}, names...)
+			}
+			candidates = append(candidates, relationship{
+				table:        fk.Table,
+				many:         true,
+				parentColumn: fk.RefColumnName,
+				column:       fk.ColumnName,
+				names:        names,
+			})
+		}
+	}
+
+	// Many-to-many: a join table references both tables
+	for _, left := range fkeys {
+		if left.RefTable != parent || left.Table == parent || left.Table == embed.Name {
+			continue
+		}
+		for _, right := range fkeys {
+			if right.Table != left.Table || right.Name == left.Name || right.RefTable != embed.Name {
+				continue
+			}
+			candidates = append(candidates, relationship{
+				table:         right.RefTable,
+				many:          true,
+				parentColumn:  left.RefColumnName,
+				column:        left.ColumnName,
+				through:       left.Table,
+				throughColumn: right.ColumnName,
+				targetColumn:  right.RefColumnName,
+				names:         []string{left.Table, left.Name, right.Name},
+			})
+		}
+	}
+
+	if embed.Hint != "" {
+		var hinted []relationship
+		for _, candidate := range candidates {
+			for _, name := range candidate.names {
+				if name == embed.Hint {
+					hinted = append(hinted, candidate)
+					break
+				}
+			}
+		}
+		candidates = hinted
+	}
+
+	switch len(candidates) {
+	case 0:
+		return relationship{}, fmt.Errorf("%w %q in %q: no foreign key relationship found", errUnknownRelationship, embed.Name, parent)
+	case 1:
+		return candidates[0], nil
+	}
+
+	hints := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		hints[i] = candidate.names[0]
+	}
+	return relationship{}, fmt.Errorf("%w %q in %q: more than one relationship found, pick one with !%s",
+		errUnknownRelationship, embed.Name, parent, strings.Join(hints, ", !"))
+}
+
+// foreignKeys loads the single column foreign keys of the schema once per
+// request. Composite keys cannot be embedded.
+func (e *embedder) foreignKeys() ([]ForeignKey, error) {
+	if e.fkeys != nil {
+		return e.fkeys, nil
+	}
+
+	fkeys, err := getForeignKeys(e.ctx, e.database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get foreign keys: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for _, fk := range fkeys {
+		columns[fk.Table+"."+fk.Name]++
+	}
+	e.fkeys = []ForeignKey{}
+	for _, fk := range fkeys {
+		if columns[fk.Table+"."+fk.Name] == 1 {
+			e.fkeys = append(e.fkeys, fk)
+		}
+	}
+	return e.fkeys, nil
+}
+
+// authorize checks the RLS policies of the embedded table and its join
+// table for the current user
+func (e *embedder) authorize(rel relationship) error {
+	for _, table := range []string{rel.table, rel.through} {
+		if table == "" {
+			continue
+		}
+		decision, err := e.rlsEngine.CheckRLS(e.ctx, table, "select", requestClaims(e.c).Role)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate row-level security policies: %w", err)
+		}
+		if !decision.Allowed {
+			return fmt.Errorf("%w %q", errEmbedDenied, table)
+		}
+	}
+	return nil
+}
+
+// alias returns a new table alias for an embedded resource
+func (e *embedder) alias() string {
+	e.aliases++
+	return fmt.Sprintf("embed_%d", e.aliases)
+}
@@ -3,9 +3,11 @@ package routes
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackson/supabase-go/db"
 	"github.com/jackson/supabase-go/middleware"
 )
@@ -16,6 +18,141 @@ type QueryRequest struct {
 	Parameters  []interface{} `json:"parameters,omitempty"`
 }
 
+// identityFunctions matches set_config and the functions that run SQL
+// built from strings, where set_config could be spelled in pieces
+var identityFunctions = regexp.MustCompile(`(?i)\bset_config\b|\bquery_to_xml|\bcursor_to_xml|\bdblink`)
+
+// changesIdentity reports whether raw SQL could change the role or the
+// request.* settings it runs with. Requests switch to their role from the
+// server's login role, which any query could otherwise switch back to.
+func changesIdentity(sql string) bool {
+	switch strings.ToUpper(leadingKeyword(sql)) {
+	case "SET", "RESET", "DO":
+		return true
+	}
+	return identityFunctions.MatchString(sql)
+}
+
+// multipleStatements reports whether sql holds more than one statement. A
+// trailing semicolon is allowed. Semicolons inside string literals, quoted
+// identifiers, dollar quotes and comments do not separate statements.
+func multipleStatements(sql string) bool {
+	ended := false
+	for i := 0; i < len(sql); i++ {
+		ch := sql[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\f':
+			continue
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				return false
+			}
+			i += end
+			continue
+		case strings.HasPrefix(sql[i:], "/*"):
+			depth := 0
+			for ; i < len(sql); i++ {
+				if strings.HasPrefix(sql[i:], "/*") {
+					depth++
+					i++
+				} else if strings.HasPrefix(sql[i:], "*/") {
+					depth--
+					i++
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			continue
+		}
+
+		// Anything but whitespace and comments after a semicolon starts
+		// another statement
+		if ended {
+			return true
+		}
+
+		switch {
+		case ch == ';':
+			ended = true
+		case ch == '\'':
+			// E'...' strings escape quotes with backslashes as well
+			escapes := i > 0 && (sql[i-1] == 'E' || sql[i-1] == 'e') && (i == 1 || !isIdentChar(sql[i-2]))
+			for i++; i < len(sql); i++ {
+				if escapes && sql[i] == '\\' {
+					i++
+				} else if sql[i] == '\'' {
+					if i+1 < len(sql) && sql[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+		case ch == '"':
+			for i++; i < len(sql) && sql[i] != '"'; i++ {
+			}
+		case ch == '$' && (i == 0 || !isIdentChar(sql[i-1])):
+			// Dollar quotes are $$ or $tag$, anything else is a parameter
+			end := i + 1
+			for end < len(sql) && sql[end] != '$' && isIdentChar(sql[end]) {
+				end++
+			}
+			if end < len(sql) && sql[end] == '$' && (end == i+1 || isIdentStart(sql[i+1])) {
+				tag := sql[i : end+1]
+				body := strings.Index(sql[end+1:], tag)
+				if body < 0 {
+					return false
+				}
+				i = end + body + len(tag)
+			}
+		}
+	}
+	return false
+}
+
+// leadingKeyword returns the first word of a statement, skipping whitespace
+// and comments. Block comments nest in PostgreSQL.
+func leadingKeyword(sql string) string {
+	i := 0
+	for i < len(sql) {
+		switch {
+		case sql[i] == ' ' || sql[i] == '\t' || sql[i] == '\n' || sql[i] == '\r' || sql[i] == '\f' || sql[i] == '(':
+			i++
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				return ""
+			}
+			i += end + 1
+		case strings.HasPrefix(sql[i:], "/*"):
+			depth := 0
+			for i < len(sql) {
+				if strings.HasPrefix(sql[i:], "/*") {
+					depth++
+					i += 2
+				} else if strings.HasPrefix(sql[i:], "*/") {
+					depth--
+					i += 2
+					if depth == 0 {
+						break
+					}
+				} else {
+					i++
+				}
+			}
+		default:
+			end := i
+			for end < len(sql) && (sql[end] == '_' || sql[end] >= 'a' && sql[end] <= 'z' || sql[end] >= 'A' && sql[end] <= 'Z') {
+				end++
+			}
+			return sql[i:end]
+		}
+	}
+	return ""
+}
+
 // ExecuteQuery handles execution of custom SQL queries
 func ExecuteQuery(database *db.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -36,9 +173,23 @@ func ExecuteQuery(database *db.DB) fiber.Handler {
 			})
 		}
 
+		// The simple protocol runs every statement in a string, so a second
+		// statement could switch back to the login role
+		if multipleStatements(req.SQL) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Only a single SQL statement is allowed",
+			})
+		}
+
+		// Queries may not leave the caller's role or rewrite their claims
+		if changesIdentity(req.SQL) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Queries may not change the role or request settings",
+			})
+		}
+
 		// Extract user information for RLS checks
-		user := c.Locals("user")
-		userID := c.Locals("userId")
+		rc := requestClaims(c)
 		
 		// Check if the query is a read-only SELECT query
 		isSelect, err := isSelectQuery(req.SQL)
@@ -48,41 +199,60 @@ func ExecuteQuery(database *db.DB) fiber.Handler {
 			})
 		}
 
-		// Apply RLS for write operations (non-SELECT queries)
-		if !isSelect {
-			allowed, err := middleware.CheckRLS(database, user, "", "write")
-			if err != nil || !allowed {
-				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-					"error": "Access denied by row-level security policy for write operations",
-				})
-			}
+		// Read-only callers may only run SELECT queries
+		readOnly := middleware.IsReadOnly(c)
+		if readOnly && !isSelect {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Read-only access only allows SELECT queries",
+			})
 		}
 
-		// Execute the query
+		// Raw SQL is not tied to a single table, so the per-table policies are
+		// enforced by PostgreSQL below; anonymous callers may not write at all
+		if !isSelect && rc.UserID == "" {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Access denied by row-level security policy for write operations",
+			})
+		}
+
+		// Execute the query as the caller so RLS policies apply
 		var result interface{}
-		var queryErr error
+		queryErr := database.WithRequestClaims(ctx, rc, func(tx pgx.Tx) error {
+			// isSelectQuery is only a lexical check, so PostgreSQL enforces
+			// read-only access as well
+			if readOnly {
+				if _, err := tx.Exec(ctx, "SET TRANSACTION READ ONLY"); err != nil {
+					return err
+				}
+			}
 
-		if isSelect {
-			// For SELECT queries, return rows
-			rows, err := database.Query(ctx, req.SQL, req.Parameters...)
+			// The extended protocol accepts a single statement only, even
+			// without parameters, where Exec would use the simple protocol
+			args := append([]interface{}{pgx.QueryExecModeExec}, req.Parameters...)
+			rows, err := tx.Query(ctx, req.SQL, args...)
 			if err != nil {
-				queryErr = err
-			} else {
-				defer rows.Close()
-				result, queryErr = pgxRowsToJSON(rows)
+				return err
+			}
+			defer rows.Close()
+
+			if isSelect {
+				// For SELECT queries, return rows
+				result, err = pgxRowsToJSON(rows)
+				return err
 			}
-		} else {
+
 			// For non-SELECT queries, return command tag
-			commandTag, err := database.Exec(ctx, req.SQL, req.Parameters...)
-			if err != nil {
-				queryErr = err
-			} else {
-				result = fiber.Map{
-					"rows_affected": commandTag.RowsAffected(),
-					"command":       commandTag.String(),
-				}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
 			}
-		}
+			commandTag := rows.CommandTag()
+			result = fiber.Map{
+				"rows_affected": commandTag.RowsAffected(),
+				"command":       commandTag.String(),
+			}
+			return nil
+		})
 
 		if queryErr != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
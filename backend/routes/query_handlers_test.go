@@ -0,0 +1,86 @@
+package routes
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestExecuteQueryRejectsStackedStatements(t *testing.T) {
+	app := fiber.New()
+	// The request is rejected before the database is used
+	app.Post("/api/query", ExecuteQuery(nil))
+
+	for _, sql := range []string{
+		"SELECT 1; RESET ROLE; SELECT * FROM api_keys",
+		"SELECT 1; SET ROLE postgres",
+	} {
+		body := strings.NewReader(`{"sql": "` + sql + `"}`)
+		req := httptest.NewRequest("POST", "/api/query", body)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("%q: status %d, want %d", sql, resp.StatusCode, fiber.StatusBadRequest)
+		}
+	}
+}
+
+func TestMultipleStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want bool
+	}{
+		{"single", "SELECT 1", false},
+		{"trailing semicolon", "SELECT 1;", false},
+		{"trailing comment", "SELECT 1; -- done", false},
+		{"stacked reset role", "SELECT 1; RESET ROLE; SELECT * FROM api_keys", true},
+		{"stacked set role", "SELECT 1;SET ROLE postgres", true},
+		{"after block comment", "SELECT 1; /* x */ RESET ROLE", true},
+		{"semicolon in string", "SELECT 'a;b'", false},
+		{"doubled quote", "SELECT 'it''s;'", false},
+		{"escape string", `SELECT E'\';' ; RESET ROLE`, true},
+		{"escape string alone", `SELECT E'\';'`, false},
+		{"quoted identifier", `SELECT 1 AS "a;b"`, false},
+		{"dollar quote", "SELECT $$;$$", false},
+		{"tagged dollar quote", "SELECT $fn$ ; $fn$", false},
+		{"parameter", "SELECT $1; RESET ROLE", true},
+		{"line comment", "SELECT 1 -- ; RESET ROLE", false},
+		{"nested block comment", "SELECT 1 /* /* ; */ ; */", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := multipleStatements(tt.sql); got != tt.want {
+				t.Errorf("multipleStatements(%q) = %v, want %v", tt.sql, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangesIdentity(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"SELECT * FROM todos", false},
+		{"RESET ROLE", true},
+		{"  set role postgres", true},
+		{"/* c */ SET LOCAL request.jwt.claims = '{}'", true},
+		{"SELECT set_config('role', 'none', true)", true},
+		{"DO $$ BEGIN END $$", true},
+		{"SELECT settings FROM profiles", false},
+	}
+
+	for _, tt := range tests {
+		if got := changesIdentity(tt.sql); got != tt.want {
+			t.Errorf("changesIdentity(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
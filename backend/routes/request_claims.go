@@ -0,0 +1,45 @@
+package routes
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackson/supabase-go/db"
+	"github.com/jackson/supabase-go/middleware"
+)
+
+// requestClaims builds the database identity for the current request from
+// the locals set by the authentication middleware
+func requestClaims(c *fiber.Ctx) db.RequestClaims {
+	rc := db.RequestClaims{
+		Role:   db.AnonRole,
+		Claims: map[string]interface{}{},
+	}
+
+	userID, _ := c.Locals("userId").(string)
+	if userID == "" {
+		return rc
+	}
+	rc.UserID = userID
+	rc.OrgID, _ = c.Locals("orgId").(string)
+
+	// Users run as their primary role, authenticated unless one of their
+	// roles exists as a PostgreSQL role
+	rc.Role = db.AuthenticatedRole
+	roles, err := middleware.ResolveRoles(c)
+	if err != nil {
+		log.Printf("Failed to resolve user roles, using %s: %v", rc.Role, err)
+	} else {
+		rc.Role = roles.Primary
+	}
+
+	// Round-trip the verified token claims through JSON to get a plain map
+	if claims := c.Locals("claims"); claims != nil {
+		if data, err := json.Marshal(claims); err == nil {
+			json.Unmarshal(data, &rc.Claims)
+		}
+	}
+
+	return rc
+}
@@ -0,0 +1,458 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackson/supabase-go/db"
+	"github.com/jackson/supabase-go/middleware"
+)
+
+// Drift statuses reported for a single policy
+const (
+	driftMissingInDatabase = "missing_in_database"
+	driftUntracked         = "untracked"
+	driftMismatch          = "mismatch"
+)
+
+// DatabasePolicy represents a policy as PostgreSQL enforces it (pg_policies)
+type DatabasePolicy struct {
+	Name       string   `json:"name"`
+	TableName  string   `json:"table_name"`
+	Action     string   `json:"action"`
+	Roles      []string `json:"roles"`
+	Permissive bool     `json:"permissive"`
+	Using      string   `json:"using"`
+	WithCheck  string   `json:"with_check"`
+}
+
+// PolicyDrift describes a difference between rls_policies and pg_policies
+type PolicyDrift struct {
+	TableName   string          `json:"table_name"`
+	PolicyName  string          `json:"policy_name"`
+	Status      string          `json:"status"`
+	Differences []string        `json:"differences,omitempty"`
+	Stored      *RLSPolicy      `json:"stored,omitempty"`
+	Database    *DatabasePolicy `json:"database,omitempty"`
+}
+
+// DriftReport is the result of comparing rls_policies with pg_policies
+type DriftReport struct {
+	InSync                bool          `json:"in_sync"`
+	Policies              []PolicyDrift `json:"policies"`
+	TablesWithoutPolicies []string      `json:"tables_without_policies"`
+}
+
+// ReconcileRequest represents a request to resolve policy drift
+type ReconcileRequest struct {
+	Mode      string `json:"mode"`
+	TableName string `json:"table_name"`
+}
+
+// GetRLSDrift compares the stored policies with the ones PostgreSQL enforces
+func GetRLSDrift(database *db.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := context.Background()
+
+		report, err := detectDrift(ctx, database, c.Query("table"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to detect policy drift: %v", err),
+			})
+		}
+
+		return c.JSON(report)
+	}
+}
+
+// ReconcileRLSPolicies resolves policy drift. The import mode rewrites
+// rls_policies from pg_policies, the enforce mode rewrites the database
+// policies from rls_policies.
+func ReconcileRLSPolicies(database *db.DB, rlsEngine *middleware.RLSEngine) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := context.Background()
+
+		var req ReconcileRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid request body: %v", err),
+			})
+		}
+		if req.Mode != "import" && req.Mode != "enforce" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Mode must be either 'import' or 'enforce'",
+			})
+		}
+
+		report, err := detectDrift(ctx, database, req.TableName)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to detect policy drift: %v", err),
+			})
+		}
+
+		tx, err := database.Begin(ctx)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to begin transaction: %v", err),
+			})
+		}
+		defer tx.Rollback(ctx)
+
+		userID, _ := c.Locals("userId").(string)
+		for _, drift := range report.Policies {
+			if req.Mode == "import" {
+				err = importDrift(ctx, tx, drift, userID)
+			} else {
+				err = enforceDrift(ctx, tx, drift)
+			}
+			if err != nil {
+				message := fmt.Sprintf("Failed to reconcile policy '%s' on table '%s'", drift.PolicyName, drift.TableName)
+				return c.Status(policyErrorStatus(err)).JSON(policyErrorResponse(message, err))
+			}
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to commit reconciliation: %v", err),
+			})
+		}
+		rlsEngine.InvalidateAll()
+
+		return c.JSON(fiber.Map{
+			"mode":       req.Mode,
+			"reconciled": report.Policies,
+		})
+	}
+}
+
+// detectDrift diffs rls_policies against pg_policies, optionally for one table
+func detectDrift(ctx context.Context, database *db.DB, tableName string) (DriftReport, error) {
+	report := DriftReport{
+		Policies:              []PolicyDrift{},
+		TablesWithoutPolicies: []string{},
+	}
+
+	stored, err := listStoredPolicies(ctx, database, tableName)
+	if err != nil {
+		return report, err
+	}
+
+	actual, err := listDatabasePolicies(ctx, database, tableName)
+	if err != nil {
+		return report, err
+	}
+
+	expected, err := expectedDatabasePolicies(ctx, database, stored)
+	if err != nil {
+		return report, err
+	}
+
+	for i := range stored {
+		policy := stored[i]
+		key := policyKey(policy.TableName, policy.Name)
+
+		dbPolicy, ok := actual[key]
+		if !ok {
+			report.Policies = append(report.Policies, PolicyDrift{
+				TableName:  policy.TableName,
+				PolicyName: policy.Name,
+				Status:     driftMissingInDatabase,
+				Stored:     &policy,
+			})
+			continue
+		}
+		delete(actual, key)
+
+		if differences := comparePolicies(expected[key], dbPolicy); len(differences) > 0 {
+			dbPolicy := dbPolicy
+			report.Policies = append(report.Policies, PolicyDrift{
+				TableName:   policy.TableName,
+				PolicyName:  policy.Name,
+				Status:      driftMismatch,
+				Differences: differences,
+				Stored:      &policy,
+				Database:    &dbPolicy,
+			})
+		}
+	}
+
+	// Whatever is left exists only in the database
+	var untracked []string
+	for key := range actual {
+		untracked = append(untracked, key)
+	}
+	sort.Strings(untracked)
+	for _, key := range untracked {
+		dbPolicy := actual[key]
+		report.Policies = append(report.Policies, PolicyDrift{
+			TableName:  dbPolicy.TableName,
+			PolicyName: dbPolicy.Name,
+			Status:     driftUntracked,
+			Database:   &dbPolicy,
+		})
+	}
+
+	// Tables with RLS enabled but no policies deny every row
+	query := `
+		SELECT c.relname
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = 'public'
+		AND c.relkind = 'r'
+		AND c.relrowsecurity
+		AND ($1 = '' OR c.relname = $1)
+		AND NOT EXISTS (
+			SELECT FROM pg_policies p
+			WHERE p.schemaname = 'public' AND p.tablename = c.relname
+		)
+		ORDER BY c.relname
+	`
+	rows, err := database.Query(ctx, query, tableName)
+	if err != nil {
+		return report, fmt.Errorf("failed to query tables without policies: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return report, fmt.Errorf("failed to scan table name: %w", err)
+		}
+		report.TablesWithoutPolicies = append(report.TablesWithoutPolicies, name)
+	}
+	if err := rows.Err(); err != nil {
+		return report, fmt.Errorf("error iterating tables: %w", err)
+	}
+
+	report.InSync = len(report.Policies) == 0 && len(report.TablesWithoutPolicies) == 0
+	return report, nil
+}
+
+// listStoredPolicies returns the rls_policies rows, optionally for one table
+func listStoredPolicies(ctx context.Context, database *db.DB, tableName string) ([]RLSPolicy, error) {
+	query := `
+		SELECT ` + rlsPolicyColumns + `
+		FROM rls_policies
+		WHERE ($1 = '' OR table_name = $1)
+		ORDER BY table_name, name
+	`
+
+	rows, err := database.Query(ctx, query, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query RLS policies: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []RLSPolicy
+	for rows.Next() {
+		var policy RLSPolicy
+		if err := scanRLSPolicy(rows, &policy); err != nil {
+			return nil, fmt.Errorf("failed to scan policy: %w", err)
+		}
+		policies = append(policies, policy)
+	}
+
+	return policies, rows.Err()
+}
+
+// listDatabasePolicies returns the policies in pg_policies keyed by table and name
+func listDatabasePolicies(ctx context.Context, q db.Querier, tableName string) (map[string]DatabasePolicy, error) {
+	query := `
+		SELECT tablename, policyname, cmd, roles::text[], permissive = 'PERMISSIVE',
+			COALESCE(qual, ''), COALESCE(with_check, '')
+		FROM pg_policies
+		WHERE schemaname = 'public'
+		AND ($1 = '' OR tablename = $1)
+	`
+
+	rows, err := q.Query(ctx, query, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pg_policies: %w", err)
+	}
+	defer rows.Close()
+
+	policies := make(map[string]DatabasePolicy)
+	for rows.Next() {
+		var policy DatabasePolicy
+		if err := rows.Scan(
+			&policy.TableName,
+			&policy.Name,
+			&policy.Action,
+			&policy.Roles,
+			&policy.Permissive,
+			&policy.Using,
+			&policy.WithCheck,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan pg_policies row: %w", err)
+		}
+		policy.Action = strings.ToLower(policy.Action)
+		policies[policyKey(policy.TableName, policy.Name)] = policy
+	}
+
+	return policies, rows.Err()
+}
+
+// expectedDatabasePolicies works out how PostgreSQL would store each policy
+// by creating it under a scratch name inside a transaction that is rolled
+// back. This gives the deparsed USING and WITH CHECK text to compare with.
+func expectedDatabasePolicies(ctx context.Context, database *db.DB, stored []RLSPolicy) (map[string]DatabasePolicy, error) {
+	expected := make(map[string]DatabasePolicy)
+	if len(stored) == 0 {
+		return expected, nil
+	}
+
+	tx, err := database.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for i, policy := range stored {
+		key := policyKey(policy.TableName, policy.Name)
+
+		shadow := policy
+		shadow.Name = fmt.Sprintf("drift_check_%d", i)
+
+		// Each policy gets its own savepoint so an invalid one does not abort the rest
+		sp, err := tx.Begin(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+
+		if err := applyRLSPolicy(ctx, sp, shadow); err != nil {
+			sp.Rollback(ctx)
+			expected[key] = DatabasePolicy{Name: policy.Name, TableName: policy.TableName, Using: "invalid: " + err.Error()}
+			continue
+		}
+
+		created, err := listDatabasePolicies(ctx, sp, policy.TableName)
+		sp.Rollback(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		dbPolicy := created[policyKey(policy.TableName, shadow.Name)]
+		dbPolicy.Name = policy.Name
+		expected[key] = dbPolicy
+	}
+
+	return expected, nil
+}
+
+// comparePolicies lists the attributes that differ between two policies
+func comparePolicies(expected, actual DatabasePolicy) []string {
+	var differences []string
+
+	if expected.Action != actual.Action {
+		differences = append(differences, fmt.Sprintf("action: expected %q, found %q", expected.Action, actual.Action))
+	}
+	if expected.Permissive != actual.Permissive {
+		differences = append(differences, fmt.Sprintf("permissive: expected %t, found %t", expected.Permissive, actual.Permissive))
+	}
+	if !sameRoles(expected.Roles, actual.Roles) {
+		differences = append(differences, fmt.Sprintf("roles: expected %v, found %v", expected.Roles, actual.Roles))
+	}
+	if normalizeWhitespace(expected.Using) != normalizeWhitespace(actual.Using) {
+		differences = append(differences, fmt.Sprintf("using: expected %q, found %q", expected.Using, actual.Using))
+	}
+	if normalizeWhitespace(expected.WithCheck) != normalizeWhitespace(actual.WithCheck) {
+		differences = append(differences, fmt.Sprintf("with_check: expected %q, found %q", expected.WithCheck, actual.WithCheck))
+	}
+
+	return differences
+}
+
+// normalizeWhitespace collapses runs of whitespace so that only the
+// deparsed expressions themselves are compared
+func normalizeWhitespace(expr string) string {
+	return strings.Join(strings.Fields(expr), " ")
+}
+
+// importDrift updates rls_policies to match what the database enforces. The
+// policies are already in place, so only the rows and their version history
+// change.
+func importDrift(ctx context.Context, tx pgx.Tx, drift PolicyDrift, changedBy string) error {
+	switch drift.Status {
+	case driftMissingInDatabase:
+		if _, err := tx.Exec(ctx, "DELETE FROM rls_policies WHERE id = $1", drift.Stored.ID); err != nil {
+			return fmt.Errorf("failed to delete policy row: %w", err)
+		}
+		return recordPolicyVersion(ctx, tx, *drift.Stored, policyChange{Operation: "delete", ChangedBy: changedBy})
+	case driftUntracked:
+		p := drift.Database
+		req := RLSPolicyRequest{
+			Name:            p.Name,
+			TableName:       p.TableName,
+			Action:          p.Action,
+			Roles:           p.Roles,
+			Definition:      p.Using,
+			CheckDefinition: p.WithCheck,
+			Description:     "Imported from pg_policies",
+		}
+		policy, err := insertPolicyRow(ctx, tx, req, p.Permissive)
+		if err != nil {
+			return err
+		}
+		return recordPolicyVersion(ctx, tx, policy, policyChange{Operation: "create", ChangedBy: changedBy})
+	case driftMismatch:
+		p := drift.Database
+		req := RLSPolicyRequest{
+			Name:            drift.Stored.Name,
+			TableName:       drift.Stored.TableName,
+			Action:          p.Action,
+			Roles:           p.Roles,
+			Definition:      p.Using,
+			CheckDefinition: p.WithCheck,
+			Description:     drift.Stored.Description,
+		}
+		policy, err := updatePolicyRow(ctx, tx, drift.Stored.ID, req, p.Permissive)
+		if err != nil {
+			return err
+		}
+		return recordPolicyVersion(ctx, tx, policy, policyChange{Operation: "update", ChangedBy: changedBy})
+	}
+	return nil
+}
+
+// enforceDrift updates the database policies to match rls_policies
+func enforceDrift(ctx context.Context, tx pgx.Tx, drift PolicyDrift) error {
+	switch drift.Status {
+	case driftMissingInDatabase:
+		return applyRLSPolicy(ctx, tx, *drift.Stored)
+	case driftUntracked:
+		return dropRLSPolicy(ctx, tx, RLSPolicy{Name: drift.Database.Name, TableName: drift.Database.TableName})
+	case driftMismatch:
+		if err := dropRLSPolicy(ctx, tx, *drift.Stored); err != nil {
+			return err
+		}
+		return applyRLSPolicy(ctx, tx, *drift.Stored)
+	}
+	return nil
+}
+
+// policyKey identifies a policy by table and name
+func policyKey(tableName, policyName string) string {
+	return tableName + "." + policyName
+}
+
+// sameRoles reports whether two role lists contain the same roles
+func sameRoles(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
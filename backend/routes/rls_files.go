@@ -0,0 +1,302 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackson/supabase-go/db"
+	"github.com/jackson/supabase-go/middleware"
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyDocument is the declarative file format for RLS policies
+type PolicyDocument struct {
+	Policies []RLSPolicyRequest `json:"policies" yaml:"policies"`
+}
+
+// PolicyPlanUpdate describes a stored policy that differs from its file entry
+type PolicyPlanUpdate struct {
+	Current RLSPolicy        `json:"current"`
+	Desired RLSPolicyRequest `json:"desired"`
+	Changes []string         `json:"changes"`
+}
+
+// PolicyPlan lists the changes needed to make rls_policies match a document
+type PolicyPlan struct {
+	Create    []RLSPolicyRequest `json:"create"`
+	Update    []PolicyPlanUpdate `json:"update"`
+	Drop      []RLSPolicy        `json:"drop"`
+	Unchanged []string           `json:"unchanged"`
+}
+
+// ExportRLSPolicies returns the stored policies as a YAML document or as a
+// SQL script, depending on the format query parameter
+func ExportRLSPolicies(database *db.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := context.Background()
+
+		format := c.Query("format", "yaml")
+		if format != "yaml" && format != "sql" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Format must be either 'yaml' or 'sql'",
+			})
+		}
+
+		data, err := ExportPolicies(ctx, database, c.Query("table"), format)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to export policies: %v", err),
+			})
+		}
+
+		if format == "sql" {
+			c.Set(fiber.HeaderContentType, "application/sql")
+		} else {
+			c.Set(fiber.HeaderContentType, "application/yaml")
+		}
+		return c.Send(data)
+	}
+}
+
+// ImportRLSPolicies applies a YAML (or JSON) policy document. With
+// prune=true stored policies missing from the document are dropped, limited
+// to one table when the table query parameter is set. With dry_run=true only
+// the plan is returned.
+func ImportRLSPolicies(database *db.DB, rlsEngine *middleware.RLSEngine) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := context.Background()
+
+		doc, err := LoadPolicyDocument(c.Body())
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid policy document: %v", err),
+			})
+		}
+
+		plan, err := PlanPolicyImport(ctx, database, doc, c.Query("table"), c.QueryBool("prune"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid policy document: %v", err),
+			})
+		}
+
+		if c.QueryBool("dry_run") {
+			return c.JSON(fiber.Map{
+				"dry_run": true,
+				"plan":    plan,
+			})
+		}
+
+		userID, _ := c.Locals("userId").(string)
+		if err := ApplyPolicyPlan(ctx, database, plan, userID); err != nil {
+			return c.Status(policyErrorStatus(err)).JSON(policyErrorResponse("Failed to apply policy plan", err))
+		}
+		rlsEngine.InvalidateAll()
+
+		return c.JSON(fiber.Map{
+			"dry_run": false,
+			"plan":    plan,
+		})
+	}
+}
+
+// LoadPolicyDocument parses a YAML policy document. JSON is accepted as well
+// since it is a subset of YAML.
+func LoadPolicyDocument(data []byte) (PolicyDocument, error) {
+	var doc PolicyDocument
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return doc, err
+	}
+	return doc, nil
+}
+
+// ExportPolicies renders the stored policies, optionally for one table, as
+// a YAML document ("yaml") or a CREATE POLICY script ("sql")
+func ExportPolicies(ctx context.Context, database *db.DB, tableName, format string) ([]byte, error) {
+	policies, err := listStoredPolicies(ctx, database, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == "sql" {
+		var b strings.Builder
+		enabled := make(map[string]bool)
+		for _, policy := range policies {
+			if !enabled[policy.TableName] {
+				fmt.Fprintf(&b, "ALTER TABLE %s ENABLE ROW LEVEL SECURITY;\n", pgx.Identifier{policy.TableName}.Sanitize())
+				enabled[policy.TableName] = true
+			}
+			if policy.Description != "" {
+				fmt.Fprintf(&b, "-- %s\n", strings.ReplaceAll(policy.Description, "\n", " "))
+			}
+			fmt.Fprintf(&b, "%s;\n\n", createPolicyStatement(policy))
+		}
+		return []byte(b.String()), nil
+	}
+
+	doc := PolicyDocument{Policies: []RLSPolicyRequest{}}
+	for _, policy := range policies {
+		doc.Policies = append(doc.Policies, policyToRequest(policy))
+	}
+	return yaml.Marshal(doc)
+}
+
+// PlanPolicyImport validates a document and works out which policies to
+// create, update and drop. Stored policies in scope that the document does
+// not mention are only dropped when prune is set, and a document without
+// policies is rejected so an empty or mistyped file cannot drop everything.
+func PlanPolicyImport(ctx context.Context, database *db.DB, doc PolicyDocument, tableName string, prune bool) (PolicyPlan, error) {
+	plan := PolicyPlan{
+		Create:    []RLSPolicyRequest{},
+		Update:    []PolicyPlanUpdate{},
+		Drop:      []RLSPolicy{},
+		Unchanged: []string{},
+	}
+
+	if len(doc.Policies) == 0 {
+		return plan, fmt.Errorf("document contains no policies")
+	}
+
+	stored, err := listStoredPolicies(ctx, database, tableName)
+	if err != nil {
+		return plan, err
+	}
+	current := make(map[string]RLSPolicy, len(stored))
+	for _, policy := range stored {
+		current[policyKey(policy.TableName, policy.Name)] = policy
+	}
+
+	seen := make(map[string]bool)
+	for _, req := range doc.Policies {
+		if tableName != "" && req.TableName != tableName {
+			return plan, fmt.Errorf("policy '%s' is for table '%s', expected '%s'", req.Name, req.TableName, tableName)
+		}
+
+		// Same validation as CreateRLSPolicy
+		if err := validatePolicyRequest(req); err != nil {
+			return plan, fmt.Errorf("policy '%s': %w", req.Name, err)
+		}
+		exists, err := tableExists(ctx, database, req.TableName)
+		if err != nil {
+			return plan, fmt.Errorf("failed to check table existence: %w", err)
+		}
+		if !exists {
+			return plan, fmt.Errorf("policy '%s': table '%s' does not exist", req.Name, req.TableName)
+		}
+
+		key := policyKey(req.TableName, req.Name)
+		if seen[key] {
+			return plan, fmt.Errorf("policy '%s' is defined more than once for table '%s'", req.Name, req.TableName)
+		}
+		seen[key] = true
+
+		policy, ok := current[key]
+		if !ok {
+			plan.Create = append(plan.Create, req)
+			continue
+		}
+
+		if changes := policyChanges(policy, req); len(changes) > 0 {
+			plan.Update = append(plan.Update, PolicyPlanUpdate{Current: policy, Desired: req, Changes: changes})
+		} else {
+			plan.Unchanged = append(plan.Unchanged, key)
+		}
+	}
+
+	for _, policy := range stored {
+		if prune && !seen[policyKey(policy.TableName, policy.Name)] {
+			plan.Drop = append(plan.Drop, policy)
+		}
+	}
+
+	return plan, nil
+}
+
+// ApplyPolicyPlan executes a plan in a single transaction through the same
+// paths the RLS CRUD endpoints use, recording a revision for every change
+func ApplyPolicyPlan(ctx context.Context, database *db.DB, plan PolicyPlan, changedBy string) error {
+	tx, err := database.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Drop first so renamed policies do not collide
+	for _, policy := range plan.Drop {
+		if err := deletePolicy(ctx, tx, policy, policyChange{Operation: "delete", ChangedBy: changedBy}); err != nil {
+			return fmt.Errorf("policy '%s': %w", policy.Name, err)
+		}
+	}
+
+	for _, update := range plan.Update {
+		permissive := update.Desired.Permissive == nil || *update.Desired.Permissive
+		change := policyChange{Operation: "update", ChangedBy: changedBy}
+		if _, err := updatePolicy(ctx, tx, update.Current, update.Desired, permissive, change); err != nil {
+			return fmt.Errorf("policy '%s': %w", update.Current.Name, err)
+		}
+	}
+
+	for _, req := range plan.Create {
+		permissive := req.Permissive == nil || *req.Permissive
+		change := policyChange{Operation: "create", ChangedBy: changedBy}
+		if _, err := createPolicy(ctx, tx, req, permissive, change); err != nil {
+			return fmt.Errorf("policy '%s': %w", req.Name, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit policy plan: %w", err)
+	}
+
+	return nil
+}
+
+// policyToRequest converts a stored policy to its file representation
+func policyToRequest(policy RLSPolicy) RLSPolicyRequest {
+	req := RLSPolicyRequest{
+		Name:            policy.Name,
+		TableName:       policy.TableName,
+		Action:          policy.Action,
+		Roles:           policy.Roles,
+		Definition:      policy.Definition,
+		CheckDefinition: policy.CheckDefinition,
+		Description:     policy.Description,
+	}
+
+	// Permissive is the default, so only restrictive policies spell it out
+	if !policy.Permissive {
+		permissive := false
+		req.Permissive = &permissive
+	}
+
+	return req
+}
+
+// policyChanges lists the fields of a stored policy that a request would change
+func policyChanges(policy RLSPolicy, req RLSPolicyRequest) []string {
+	var changes []string
+
+	if policy.Action != req.Action {
+		changes = append(changes, "action")
+	}
+	if !sameRoles(policy.Roles, req.Roles) {
+		changes = append(changes, "roles")
+	}
+	if policy.Definition != req.Definition {
+		changes = append(changes, "definition")
+	}
+	if policy.CheckDefinition != req.CheckDefinition {
+		changes = append(changes, "check_definition")
+	}
+	if policy.Permissive != (req.Permissive == nil || *req.Permissive) {
+		changes = append(changes, "permissive")
+	}
+	if policy.Description != req.Description {
+		changes = append(changes, "description")
+	}
+
+	return changes
+}
@@ -2,38 +2,61 @@ package routes
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackson/supabase-go/db"
+	"github.com/jackson/supabase-go/middleware"
 )
 
 // RLSPolicy represents a row-level security policy
 type RLSPolicy struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	TableName   string    `json:"table_name"`
-	Action      string    `json:"action"`
-	Roles       []string  `json:"roles"`
-	Definition  string    `json:"definition"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Description string    `json:"description"`
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	TableName       string    `json:"table_name"`
+	Action          string    `json:"action"`
+	Roles           []string  `json:"roles"`
+	Definition      string    `json:"definition"`
+	CheckDefinition string    `json:"check_definition"`
+	Permissive      bool      `json:"permissive"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	Description     string    `json:"description"`
 }
 
 // RLSPolicyRequest represents a request to create or update a RLS policy
 type RLSPolicyRequest struct {
-	Name        string   `json:"name"`
-	TableName   string   `json:"table_name"`
-	Action      string   `json:"action"`
-	Roles       []string `json:"roles"`
-	Definition  string   `json:"definition"`
-	Description string   `json:"description"`
+	Name            string   `json:"name" yaml:"name"`
+	TableName       string   `json:"table_name" yaml:"table_name"`
+	Action          string   `json:"action" yaml:"action"`
+	Roles           []string `json:"roles" yaml:"roles,flow"`
+	Definition      string   `json:"definition" yaml:"definition,omitempty"`
+	CheckDefinition string   `json:"check_definition" yaml:"check_definition,omitempty"`
+	Permissive      *bool    `json:"permissive" yaml:"permissive,omitempty"`
+	Description     string   `json:"description" yaml:"description,omitempty"`
+
+	// ID is only set when restoring a deleted policy, so that its
+	// version history continues under the same ID
+	ID string `json:"-" yaml:"-"`
+}
+
+// policyChange identifies who changed a policy and how, for its version history
+type policyChange struct {
+	Operation string
+	ChangedBy string
 }
 
+// rlsPolicyColumns is the column list used when reading rls_policies rows
+const rlsPolicyColumns = `
+	id, name, table_name, action, roles, definition, check_definition,
+	permissive, created_at, updated_at, description
+`
+
 // GetRLSPolicies returns all RLS policies
 func GetRLSPolicies(database *db.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -43,9 +66,7 @@ func GetRLSPolicies(database *db.DB) fiber.Handler {
 		tableName := c.Query("table")
 		
 		query := `
-			SELECT 
-				id, name, table_name, action, roles, definition, 
-				created_at, updated_at, description
+			SELECT ` + rlsPolicyColumns + `
 			FROM rls_policies
 		`
 		
@@ -71,28 +92,14 @@ func GetRLSPolicies(database *db.DB) fiber.Handler {
 		var policies []RLSPolicy
 		for rows.Next() {
 			var policy RLSPolicy
-			var rolesJson string
 			
-			err := rows.Scan(
-				&policy.ID,
-				&policy.Name,
-				&policy.TableName,
-				&policy.Action,
-				&rolesJson,
-				&policy.Definition,
-				&policy.CreatedAt,
-				&policy.UpdatedAt,
-				&policy.Description,
-			)
+			err := scanRLSPolicy(rows, &policy)
 			if err != nil {
 				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 					"error": fmt.Sprintf("Failed to scan policy: %v", err),
 				})
 			}
 			
-			// Parse roles from JSON
-			policy.Roles = parseRoles(rolesJson)
-			
 			policies = append(policies, policy)
 		}
 		
@@ -109,9 +116,7 @@ func GetRLSPolicy(database *db.DB) fiber.Handler {
 		policyID := c.Params("id")
 		
 		query := `
-			SELECT 
-				id, name, table_name, action, roles, definition, 
-				created_at, updated_at, description
+			SELECT ` + rlsPolicyColumns + `
 			FROM rls_policies
 			WHERE id = $1
 		`
@@ -119,19 +124,8 @@ func GetRLSPolicy(database *db.DB) fiber.Handler {
 		row := database.QueryRow(ctx, query, policyID)
 		
 		var policy RLSPolicy
-		var rolesJson string
-		
-		err := row.Scan(
-			&policy.ID,
-			&policy.Name,
-			&policy.TableName,
-			&policy.Action,
-			&rolesJson,
-			&policy.Definition,
-			&policy.CreatedAt,
-			&policy.UpdatedAt,
-			&policy.Description,
-		)
+		
+		err := scanRLSPolicy(row, &policy)
 		if err != nil {
 			if err == pgx.ErrNoRows {
 				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -143,15 +137,12 @@ func GetRLSPolicy(database *db.DB) fiber.Handler {
 			})
 		}
 		
-		// Parse roles from JSON
-		policy.Roles = parseRoles(rolesJson)
-		
 		return c.JSON(policy)
 	}
 }
 
 // CreateRLSPolicy creates a new RLS policy
-func CreateRLSPolicy(database *db.DB) fiber.Handler {
+func CreateRLSPolicy(database *db.DB, rlsEngine *middleware.RLSEngine) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		ctx := context.Background()
 		
@@ -163,11 +154,12 @@ func CreateRLSPolicy(database *db.DB) fiber.Handler {
 		}
 		
 		// Validate request
-		if req.Name == "" || req.TableName == "" || req.Action == "" || len(req.Roles) == 0 || req.Definition == "" {
+		if err := validatePolicyRequest(req); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Name, table_name, action, roles, and definition are required",
+				"error": fmt.Sprintf("Invalid policy: %v", err),
 			})
 		}
+		permissive := req.Permissive == nil || *req.Permissive
 		
 		// Check if table exists
 		tableExists, err := tableExists(ctx, database, req.TableName)
@@ -195,65 +187,34 @@ func CreateRLSPolicy(database *db.DB) fiber.Handler {
 			})
 		}
 		
-		// Create policy in the database
-		query := `
-			INSERT INTO rls_policies (
-				name, table_name, action, roles, definition, description
-			) VALUES (
-				$1, $2, $3, $4, $5, $6
-			) RETURNING 
-				id, name, table_name, action, roles, definition, 
-				created_at, updated_at, description
-		`
-		
-		row := database.QueryRow(
-			ctx,
-			query,
-			req.Name,
-			req.TableName,
-			req.Action,
-			rolesArrayToJson(req.Roles),
-			req.Definition,
-			req.Description,
-		)
-		
-		var policy RLSPolicy
-		var rolesJson string
-		
-		err = row.Scan(
-			&policy.ID,
-			&policy.Name,
-			&policy.TableName,
-			&policy.Action,
-			&rolesJson,
-			&policy.Definition,
-			&policy.CreatedAt,
-			&policy.UpdatedAt,
-			&policy.Description,
-		)
+		// Store and apply the policy in a single transaction
+		tx, err := database.Begin(ctx)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": fmt.Sprintf("Failed to create policy: %v", err),
+				"error": fmt.Sprintf("Failed to begin transaction: %v", err),
 			})
 		}
+		defer tx.Rollback(ctx)
 		
-		// Parse roles from JSON
-		policy.Roles = parseRoles(rolesJson)
-		
-		// Apply the policy to the table
-		err = applyRLSPolicy(ctx, database, policy)
+		userID, _ := c.Locals("userId").(string)
+		policy, err := createPolicy(ctx, tx, req, permissive, policyChange{Operation: "create", ChangedBy: userID})
 		if err != nil {
+			return c.Status(policyErrorStatus(err)).JSON(policyErrorResponse("Failed to create policy", err))
+		}
+		
+		if err := tx.Commit(ctx); err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": fmt.Sprintf("Failed to apply policy: %v", err),
+				"error": fmt.Sprintf("Failed to commit policy: %v", err),
 			})
 		}
+		rlsEngine.Invalidate(policy.TableName)
 		
 		return c.Status(fiber.StatusCreated).JSON(policy)
 	}
 }
 
 // UpdateRLSPolicy updates an existing RLS policy
-func UpdateRLSPolicy(database *db.DB) fiber.Handler {
+func UpdateRLSPolicy(database *db.DB, rlsEngine *middleware.RLSEngine) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		ctx := context.Background()
 		policyID := c.Params("id")
@@ -279,6 +240,19 @@ func UpdateRLSPolicy(database *db.DB) fiber.Handler {
 			})
 		}
 		
+		// Validate request
+		if err := validatePolicyRequest(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid policy: %v", err),
+			})
+		}
+
+		// Keep the existing mode unless the request sets one
+		permissive := oldPolicy.Permissive
+		if req.Permissive != nil {
+			permissive = *req.Permissive
+		}
+		
 		// Check if name is unique (if changed)
 		if req.Name != oldPolicy.Name || req.TableName != oldPolicy.TableName {
 			policyExists, err := policyExists(ctx, database, req.Name, req.TableName, policyID)
@@ -294,80 +268,35 @@ func UpdateRLSPolicy(database *db.DB) fiber.Handler {
 			}
 		}
 		
-		// Prepare update query
-		query := `
-			UPDATE rls_policies
-			SET 
-				name = $1,
-				table_name = $2,
-				action = $3,
-				roles = $4,
-				definition = $5,
-				description = $6,
-				updated_at = NOW()
-			WHERE id = $7
-			RETURNING 
-				id, name, table_name, action, roles, definition, 
-				created_at, updated_at, description
-		`
-		
-		row := database.QueryRow(
-			ctx,
-			query,
-			req.Name,
-			req.TableName,
-			req.Action,
-			rolesArrayToJson(req.Roles),
-			req.Definition,
-			req.Description,
-			policyID,
-		)
-		
-		var policy RLSPolicy
-		var rolesJson string
-		
-		err = row.Scan(
-			&policy.ID,
-			&policy.Name,
-			&policy.TableName,
-			&policy.Action,
-			&rolesJson,
-			&policy.Definition,
-			&policy.CreatedAt,
-			&policy.UpdatedAt,
-			&policy.Description,
-		)
+		// Update, drop and re-create the policy in a single transaction
+		tx, err := database.Begin(ctx)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": fmt.Sprintf("Failed to update policy: %v", err),
+				"error": fmt.Sprintf("Failed to begin transaction: %v", err),
 			})
 		}
+		defer tx.Rollback(ctx)
 		
-		// Parse roles from JSON
-		policy.Roles = parseRoles(rolesJson)
-		
-		// Drop the old policy
-		err = dropRLSPolicy(ctx, database, oldPolicy)
+		userID, _ := c.Locals("userId").(string)
+		policy, err := updatePolicy(ctx, tx, oldPolicy, req, permissive, policyChange{Operation: "update", ChangedBy: userID})
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": fmt.Sprintf("Failed to drop old policy: %v", err),
-			})
+			return c.Status(policyErrorStatus(err)).JSON(policyErrorResponse("Failed to update policy", err))
 		}
 		
-		// Apply the updated policy
-		err = applyRLSPolicy(ctx, database, policy)
-		if err != nil {
+		if err := tx.Commit(ctx); err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": fmt.Sprintf("Failed to apply updated policy: %v", err),
+				"error": fmt.Sprintf("Failed to commit policy: %v", err),
 			})
 		}
+		rlsEngine.Invalidate(oldPolicy.TableName)
+		rlsEngine.Invalidate(policy.TableName)
 		
 		return c.JSON(policy)
 	}
 }
 
 // DeleteRLSPolicy deletes a RLS policy
-func DeleteRLSPolicy(database *db.DB) fiber.Handler {
+func DeleteRLSPolicy(database *db.DB, rlsEngine *middleware.RLSEngine) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		ctx := context.Background()
 		policyID := c.Params("id")
@@ -386,22 +315,26 @@ func DeleteRLSPolicy(database *db.DB) fiber.Handler {
 			})
 		}
 		
-		// Drop the policy
-		err = dropRLSPolicy(ctx, database, policy)
+		// Drop the policy and its row in a single transaction
+		tx, err := database.Begin(ctx)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": fmt.Sprintf("Failed to drop policy: %v", err),
+				"error": fmt.Sprintf("Failed to begin transaction: %v", err),
 			})
 		}
+		defer tx.Rollback(ctx)
 		
-		// Delete from our policies table
-		query := "DELETE FROM rls_policies WHERE id = $1"
-		_, err = database.Exec(ctx, query, policyID)
-		if err != nil {
+		userID, _ := c.Locals("userId").(string)
+		if err := deletePolicy(ctx, tx, policy, policyChange{Operation: "delete", ChangedBy: userID}); err != nil {
+			return c.Status(policyErrorStatus(err)).JSON(policyErrorResponse("Failed to delete policy", err))
+		}
+		
+		if err := tx.Commit(ctx); err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": fmt.Sprintf("Failed to delete policy: %v", err),
+				"error": fmt.Sprintf("Failed to commit policy deletion: %v", err),
 			})
 		}
+		rlsEngine.Invalidate(policy.TableName)
 		
 		return c.SendStatus(fiber.StatusNoContent)
 	}
@@ -449,15 +382,17 @@ func policyExists(ctx context.Context, database *db.DB, name, tableName, exclude
 // Helper function to get a policy by ID
 func getPolicy(ctx context.Context, database *db.DB, policyID string, policy *RLSPolicy) error {
 	query := `
-		SELECT 
-			id, name, table_name, action, roles, definition, 
-			created_at, updated_at, description
+		SELECT ` + rlsPolicyColumns + `
 		FROM rls_policies
 		WHERE id = $1
 	`
 	
 	row := database.QueryRow(ctx, query, policyID)
-	
+	return scanRLSPolicy(row, policy)
+}
+
+// scanRLSPolicy scans a row selected with rlsPolicyColumns into policy
+func scanRLSPolicy(row pgx.Row, policy *RLSPolicy) error {
 	var rolesJson string
 	err := row.Scan(
 		&policy.ID,
@@ -466,59 +401,312 @@ func getPolicy(ctx context.Context, database *db.DB, policyID string, policy *RL
 		&policy.Action,
 		&rolesJson,
 		&policy.Definition,
+		&policy.CheckDefinition,
+		&policy.Permissive,
 		&policy.CreatedAt,
 		&policy.UpdatedAt,
 		&policy.Description,
 	)
-	
 	if err != nil {
 		return err
 	}
-	
+
 	// Parse roles from JSON
 	policy.Roles = parseRoles(rolesJson)
 	return nil
 }
 
+// createPolicy stores a new policy in rls_policies and applies it to its table
+func createPolicy(ctx context.Context, tx pgx.Tx, req RLSPolicyRequest, permissive bool, change policyChange) (RLSPolicy, error) {
+	policy, err := insertPolicyRow(ctx, tx, req, permissive)
+	if err != nil {
+		return policy, err
+	}
+
+	if err := applyRLSPolicy(ctx, tx, policy); err != nil {
+		return policy, err
+	}
+
+	if err := recordPolicyVersion(ctx, tx, policy, change); err != nil {
+		return policy, err
+	}
+
+	return policy, nil
+}
+
+// insertPolicyRow inserts a policy row without touching its table
+func insertPolicyRow(ctx context.Context, tx pgx.Tx, req RLSPolicyRequest, permissive bool) (RLSPolicy, error) {
+	query := `
+		INSERT INTO rls_policies (
+			id, name, table_name, action, roles, definition, check_definition,
+			permissive, description
+		) VALUES (
+			COALESCE(NULLIF($1, '')::uuid, uuid_generate_v4()), $2, $3, $4, $5, $6, $7, $8, $9
+		) RETURNING ` + rlsPolicyColumns
+
+	row := tx.QueryRow(
+		ctx,
+		query,
+		req.ID,
+		req.Name,
+		req.TableName,
+		req.Action,
+		rolesArrayToJson(req.Roles),
+		req.Definition,
+		req.CheckDefinition,
+		permissive,
+		req.Description,
+	)
+
+	var policy RLSPolicy
+	if err := scanRLSPolicy(row, &policy); err != nil {
+		return policy, fmt.Errorf("failed to store policy: %w", err)
+	}
+	return policy, nil
+}
+
+// updatePolicy updates a policy row and replaces the policy on its table
+func updatePolicy(ctx context.Context, tx pgx.Tx, oldPolicy RLSPolicy, req RLSPolicyRequest, permissive bool, change policyChange) (RLSPolicy, error) {
+	policy, err := updatePolicyRow(ctx, tx, oldPolicy.ID, req, permissive)
+	if err != nil {
+		return policy, err
+	}
+
+	if err := dropRLSPolicy(ctx, tx, oldPolicy); err != nil {
+		return policy, fmt.Errorf("failed to drop old policy: %w", err)
+	}
+
+	if err := applyRLSPolicy(ctx, tx, policy); err != nil {
+		return policy, err
+	}
+
+	if err := recordPolicyVersion(ctx, tx, policy, change); err != nil {
+		return policy, err
+	}
+
+	return policy, nil
+}
+
+// updatePolicyRow updates a policy row without touching its table
+func updatePolicyRow(ctx context.Context, tx pgx.Tx, policyID string, req RLSPolicyRequest, permissive bool) (RLSPolicy, error) {
+	query := `
+		UPDATE rls_policies
+		SET 
+			name = $1,
+			table_name = $2,
+			action = $3,
+			roles = $4,
+			definition = $5,
+			check_definition = $6,
+			permissive = $7,
+			description = $8,
+			updated_at = NOW()
+		WHERE id = $9
+		RETURNING ` + rlsPolicyColumns
+
+	row := tx.QueryRow(
+		ctx,
+		query,
+		req.Name,
+		req.TableName,
+		req.Action,
+		rolesArrayToJson(req.Roles),
+		req.Definition,
+		req.CheckDefinition,
+		permissive,
+		req.Description,
+		policyID,
+	)
+
+	var policy RLSPolicy
+	if err := scanRLSPolicy(row, &policy); err != nil {
+		return policy, fmt.Errorf("failed to store policy: %w", err)
+	}
+	return policy, nil
+}
+
+// deletePolicy drops a policy from its table and removes its row
+func deletePolicy(ctx context.Context, tx pgx.Tx, policy RLSPolicy, change policyChange) error {
+	if err := dropRLSPolicy(ctx, tx, policy); err != nil {
+		return fmt.Errorf("failed to drop policy: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM rls_policies WHERE id = $1", policy.ID); err != nil {
+		return fmt.Errorf("failed to delete policy row: %w", err)
+	}
+
+	// The delete revision keeps the last state so it can be rolled back to
+	return recordPolicyVersion(ctx, tx, policy, change)
+}
+
 // Helper function to apply a RLS policy to the database
-func applyRLSPolicy(ctx context.Context, database *db.DB, policy RLSPolicy) error {
+func applyRLSPolicy(ctx context.Context, tx pgx.Tx, policy RLSPolicy) error {
+	// Reject unknown roles and non-boolean expressions before any DDL runs
+	if err := validatePolicyInDatabase(ctx, tx, policy); err != nil {
+		return err
+	}
+
 	// First enable row-level security on the table
 	enableQuery := fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY",
 		pgx.Identifier{policy.TableName}.Sanitize())
 	
-	_, err := database.Exec(ctx, enableQuery)
+	_, err := tx.Exec(ctx, enableQuery)
 	if err != nil {
-		return fmt.Errorf("failed to enable RLS on table: %w", err)
+		return fmt.Errorf("failed to enable RLS on table: %w", &ddlError{statement: enableQuery, err: err})
 	}
 	
 	// Create the policy
-	createQuery := fmt.Sprintf(
-		"CREATE POLICY %s ON %s FOR %s TO %s USING (%s)",
-		pgx.Identifier{policy.Name}.Sanitize(),
-		pgx.Identifier{policy.TableName}.Sanitize(),
-		strings.ToUpper(policy.Action),
-		strings.Join(policy.Roles, ", "),
-		policy.Definition,
-	)
+	createQuery := createPolicyStatement(policy)
 	
-	_, err = database.Exec(ctx, createQuery)
+	_, err = tx.Exec(ctx, createQuery)
 	if err != nil {
-		return fmt.Errorf("failed to create policy: %w", err)
+		return fmt.Errorf("failed to apply policy: %w", &ddlError{statement: createQuery, err: err})
 	}
 	
 	return nil
 }
 
+// createPolicyStatement builds the CREATE POLICY statement for a policy
+func createPolicyStatement(policy RLSPolicy) string {
+	statement := fmt.Sprintf(
+		"CREATE POLICY %s ON %s AS %s FOR %s TO %s",
+		pgx.Identifier{policy.Name}.Sanitize(),
+		pgx.Identifier{policy.TableName}.Sanitize(),
+		policyMode(policy.Permissive),
+		strings.ToUpper(policy.Action),
+		quoteRoles(policy.Roles),
+	)
+	if policy.Definition != "" {
+		statement += fmt.Sprintf(" USING (%s)", policy.Definition)
+	}
+	if policy.CheckDefinition != "" {
+		statement += fmt.Sprintf(" WITH CHECK (%s)", policy.CheckDefinition)
+	}
+	return statement
+}
+
+// policyMode returns the AS clause keyword for a policy
+func policyMode(permissive bool) string {
+	if permissive {
+		return "PERMISSIVE"
+	}
+	return "RESTRICTIVE"
+}
+
+// validatePolicyRequest checks the fields of a policy request against the
+// action and expression combinations that CREATE POLICY accepts
+func validatePolicyRequest(req RLSPolicyRequest) error {
+	if req.Name == "" || req.TableName == "" || req.Action == "" || len(req.Roles) == 0 {
+		return fmt.Errorf("name, table_name, action and roles are required")
+	}
+	if err := validateRoleNames(req.Roles); err != nil {
+		return err
+	}
+
+	switch req.Action {
+	case "select", "delete":
+		if req.Definition == "" {
+			return fmt.Errorf("%s policies require a definition", req.Action)
+		}
+		if req.CheckDefinition != "" {
+			return fmt.Errorf("%s policies cannot have a check_definition", req.Action)
+		}
+	case "insert":
+		if req.CheckDefinition == "" {
+			return fmt.Errorf("insert policies require a check_definition")
+		}
+		if req.Definition != "" {
+			return fmt.Errorf("insert policies cannot have a definition, use check_definition")
+		}
+	case "update", "all":
+		if req.Definition == "" && req.CheckDefinition == "" {
+			return fmt.Errorf("%s policies require a definition or check_definition", req.Action)
+		}
+	default:
+		return fmt.Errorf("action must be one of select, insert, update, delete or all")
+	}
+
+	if err := checkExpression("definition", req.Definition); err != nil {
+		return err
+	}
+	return checkExpression("check_definition", req.CheckDefinition)
+}
+
 // Helper function to drop a RLS policy
-func dropRLSPolicy(ctx context.Context, database *db.DB, policy RLSPolicy) error {
+func dropRLSPolicy(ctx context.Context, tx pgx.Tx, policy RLSPolicy) error {
 	dropQuery := fmt.Sprintf(
 		"DROP POLICY IF EXISTS %s ON %s",
 		pgx.Identifier{policy.Name}.Sanitize(),
 		pgx.Identifier{policy.TableName}.Sanitize(),
 	)
 	
-	_, err := database.Exec(ctx, dropQuery)
-	return err
+	_, err := tx.Exec(ctx, dropQuery)
+	if err != nil {
+		return &ddlError{statement: dropQuery, err: err}
+	}
+	return nil
+}
+
+// ddlError records the policy statement that PostgreSQL rejected
+type ddlError struct {
+	statement string
+	err       error
+}
+
+func (e *ddlError) Error() string { return e.err.Error() }
+
+func (e *ddlError) Unwrap() error { return e.err }
+
+// policyErrorStatus maps errors from policy DDL to a response status.
+// Validation errors and errors raised by PostgreSQL mean the policy itself
+// was rejected.
+func policyErrorStatus(err error) int {
+	if isPolicyValidationError(err) {
+		return fiber.StatusBadRequest
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return fiber.StatusBadRequest
+	}
+	return fiber.StatusInternalServerError
+}
+
+// policyErrorResponse builds an error response that points at the part of
+// the policy statement PostgreSQL rejected
+func policyErrorResponse(message string, err error) fiber.Map {
+	resp := fiber.Map{
+		"error": fmt.Sprintf("%s: %v", message, err),
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return resp
+	}
+	if pgErr.Detail != "" {
+		resp["detail"] = pgErr.Detail
+	}
+	if pgErr.Hint != "" {
+		resp["hint"] = pgErr.Hint
+	}
+
+	var ddlErr *ddlError
+	if pgErr.Position > 0 && errors.As(err, &ddlErr) {
+		// Position is a 1-based character offset into the statement
+		statement := []rune(ddlErr.statement)
+		pos := int(pgErr.Position) - 1
+		if pos >= len(statement) {
+			pos = len(statement) - 1
+		}
+		end := pos + 20
+		if end > len(statement) {
+			end = len(statement)
+		}
+		resp["statement"] = ddlErr.statement
+		resp["position"] = pgErr.Position
+		resp["near"] = string(statement[pos:end])
+	}
+
+	return resp
 }
 
 // Helper function to convert roles array to JSON string
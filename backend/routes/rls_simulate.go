@@ -0,0 +1,349 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackson/supabase-go/db"
+	"github.com/jackson/supabase-go/middleware"
+)
+
+// SimulateRequest describes an identity and an optional draft policy to
+// evaluate against a table without changing anything
+type SimulateRequest struct {
+	TableName string                 `json:"table_name"`
+	Action    string                 `json:"action"`
+	Role      string                 `json:"role"`
+	UserID    string                 `json:"user_id"`
+	Claims    map[string]interface{} `json:"claims"`
+	Row       map[string]interface{} `json:"row"`
+	Limit     int                    `json:"limit"`
+	Draft     *RLSPolicyRequest      `json:"draft"`
+}
+
+// SimulateResult is the outcome of a policy simulation
+type SimulateResult struct {
+	TableName       string                   `json:"table_name"`
+	Action          string                   `json:"action"`
+	Role            string                   `json:"role"`
+	Allowed         bool                     `json:"allowed"`
+	VisibleRows     int                      `json:"visible_rows"`
+	Sample          []map[string]interface{} `json:"sample"`
+	MatchedPolicies []string                 `json:"matched_policies"`
+	DraftApplied    bool                     `json:"draft_applied"`
+	Error           string                   `json:"error,omitempty"`
+}
+
+// SimulateRLSPolicy runs a query as the given identity inside a transaction
+// that is always rolled back, optionally with a draft policy applied first.
+// Select, update and delete report the rows the identity can reach without
+// writing to them, insert reports whether the given row passes the WITH
+// CHECK expressions. The insert really runs, so its triggers fire and its
+// sequences advance even though the row is rolled back.
+func SimulateRLSPolicy(database *db.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := context.Background()
+
+		var req SimulateRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid request body: %v", err),
+			})
+		}
+
+		if req.TableName == "" || req.Action == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "table_name and action are required",
+			})
+		}
+		if req.Action != "select" && req.Action != "insert" && req.Action != "update" && req.Action != "delete" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "action must be one of select, insert, update or delete",
+			})
+		}
+		if req.Action == "insert" && len(req.Row) == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "insert simulations require a row",
+			})
+		}
+		if req.Role == "" {
+			req.Role = db.AuthenticatedRole
+		}
+
+		// Superusers, BYPASSRLS roles and the login role would see every row
+		usable, err := middleware.UsableRoles(ctx, database, []string{req.Role})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to check role: %v", err),
+			})
+		}
+		if !usable[req.Role] {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Role '%s' cannot be simulated", req.Role),
+			})
+		}
+		if req.Limit <= 0 || req.Limit > 100 {
+			req.Limit = 10
+		}
+
+		exists, err := tableExists(ctx, database, req.TableName)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to check table existence: %v", err),
+			})
+		}
+		if !exists {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Table '%s' does not exist", req.TableName),
+			})
+		}
+
+		// Nothing in this transaction is ever committed
+		tx, err := database.Begin(ctx)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to begin transaction: %v", err),
+			})
+		}
+		defer tx.Rollback(ctx)
+
+		result := SimulateResult{
+			TableName: req.TableName,
+			Action:    req.Action,
+			Role:      req.Role,
+			Sample:    []map[string]interface{}{},
+		}
+
+		if req.Draft != nil {
+			if err := applyDraftPolicy(ctx, tx, req); err != nil {
+				if errors.Is(err, errInvalidDraft) {
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+						"error": err.Error(),
+					})
+				}
+				return c.Status(policyErrorStatus(err)).JSON(policyErrorResponse("Failed to apply draft policy", err))
+			}
+			result.DraftApplied = true
+		}
+
+		result.MatchedPolicies, err = matchingPolicies(ctx, tx, req.TableName, req.Action, req.Role)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to list matching policies: %v", err),
+			})
+		}
+
+		// Update and delete policies are read before leaving the server role
+		var reach string
+		if req.Action == "update" || req.Action == "delete" {
+			reach, err = reachPredicate(ctx, tx, req.TableName, req.Action, req.Role)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": fmt.Sprintf("Failed to read %s policies: %v", req.Action, err),
+				})
+			}
+		}
+
+		userID := req.UserID
+		if sub, ok := req.Claims["sub"].(string); ok && userID == "" {
+			userID = sub
+		}
+		rc := db.RequestClaims{Role: req.Role, UserID: userID, Claims: req.Claims}
+		if err := db.SetRequestClaims(ctx, tx, rc); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to switch to role '%s': %v", req.Role, err),
+			})
+		}
+
+		if err := runSimulation(ctx, tx, req, reach, &result); err != nil {
+			var pgErr *pgconn.PgError
+			if !errors.As(err, &pgErr) {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": fmt.Sprintf("Failed to run simulation: %v", err),
+				})
+			}
+			// A policy violation or permission error is a valid outcome
+			result.Allowed = false
+			result.Error = pgErr.Message
+		}
+
+		return c.JSON(result)
+	}
+}
+
+// errInvalidDraft marks draft policies rejected by validation
+var errInvalidDraft = errors.New("invalid draft policy")
+
+// applyDraftPolicy creates the draft policy inside the simulation
+// transaction. A draft named like an existing policy replaces it.
+func applyDraftPolicy(ctx context.Context, tx pgx.Tx, req SimulateRequest) error {
+	draft := *req.Draft
+	draft.TableName = req.TableName
+	if draft.Name == "" {
+		draft.Name = "simulated_draft"
+	}
+	if draft.Action == "" {
+		draft.Action = req.Action
+	}
+	if len(draft.Roles) == 0 {
+		draft.Roles = []string{req.Role}
+	}
+	if err := validatePolicyRequest(draft); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidDraft, err)
+	}
+
+	policy := RLSPolicy{
+		Name:            draft.Name,
+		TableName:       draft.TableName,
+		Action:          draft.Action,
+		Roles:           draft.Roles,
+		Definition:      draft.Definition,
+		CheckDefinition: draft.CheckDefinition,
+		Permissive:      draft.Permissive == nil || *draft.Permissive,
+	}
+
+	if err := dropRLSPolicy(ctx, tx, policy); err != nil {
+		return err
+	}
+	return applyRLSPolicy(ctx, tx, policy)
+}
+
+// matchingPolicies lists the policies PostgreSQL would consider for the
+// action and role, including any draft applied in the transaction
+func matchingPolicies(ctx context.Context, tx pgx.Tx, tableName, action, role string) ([]string, error) {
+	query := `
+		SELECT policyname
+		FROM pg_policies
+		WHERE schemaname = 'public'
+		AND tablename = $1
+		AND cmd IN ($2, 'ALL')
+		AND roles && ARRAY[$3, 'public']::name[]
+		ORDER BY policyname
+	`
+
+	rows, err := tx.Query(ctx, query, tableName, strings.ToUpper(action), role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		policies = append(policies, name)
+	}
+
+	return policies, rows.Err()
+}
+
+// reachPredicate combines the USING expressions of the policies for an
+// update or delete by role the way PostgreSQL does: permissive policies are
+// ORed and restrictive ones ANDed. Without row-level security every row is
+// reachable, without a permissive policy none is.
+func reachPredicate(ctx context.Context, tx pgx.Tx, tableName, action, role string) (string, error) {
+	query := `
+		SELECT
+			c.relrowsecurity,
+			COALESCE(string_agg('(' || p.qual || ')', ' OR ') FILTER (WHERE p.permissive = 'PERMISSIVE'), ''),
+			COALESCE(string_agg('(' || p.qual || ')', ' AND ') FILTER (WHERE p.permissive = 'RESTRICTIVE'), '')
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace AND n.nspname = 'public'
+		LEFT JOIN pg_policies p ON p.schemaname = 'public'
+			AND p.tablename = c.relname
+			AND p.cmd IN ($2, 'ALL')
+			AND p.roles && ARRAY[$3, 'public']::name[]
+			AND p.qual IS NOT NULL
+		WHERE c.relname = $1
+		GROUP BY c.relrowsecurity
+	`
+
+	var enabled bool
+	var permissive, restrictive string
+	err := tx.QueryRow(ctx, query, tableName, strings.ToUpper(action), role).Scan(&enabled, &permissive, &restrictive)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case !enabled:
+		return "TRUE", nil
+	case permissive == "":
+		return "FALSE", nil
+	case restrictive == "":
+		return "(" + permissive + ")", nil
+	default:
+		return "(" + permissive + ") AND " + restrictive, nil
+	}
+}
+
+// runSimulation executes the simulated action as the current role. Update
+// and delete select the rows matching reach instead of writing to them, so
+// like a real statement they also only see the rows select policies allow.
+func runSimulation(ctx context.Context, tx pgx.Tx, req SimulateRequest, reach string, result *SimulateResult) error {
+	table := pgx.Identifier{req.TableName}.Sanitize()
+
+	var query string
+	var args []interface{}
+	switch req.Action {
+	case "select":
+		query = fmt.Sprintf("SELECT * FROM %s", table)
+	case "update", "delete":
+		var granted bool
+		err := tx.QueryRow(ctx, "SELECT has_table_privilege(current_user, $1::regclass, $2)",
+			table, strings.ToUpper(req.Action)).Scan(&granted)
+		if err != nil {
+			return err
+		}
+		if !granted {
+			result.Allowed = false
+			result.Error = fmt.Sprintf("permission denied for table %s", req.TableName)
+			return nil
+		}
+		query = fmt.Sprintf("SELECT * FROM %s WHERE %s", table, reach)
+	case "insert":
+		var columns, placeholders []string
+		for column, value := range req.Row {
+			columns = append(columns, pgx.Identifier{column}.Sanitize())
+			args = append(args, value)
+			placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+		}
+		query = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING *",
+			table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	}
+
+	// Wrap the statement so only a bounded sample is returned along with
+	// the total number of rows it reached
+	args = append(args, req.Limit)
+	query = fmt.Sprintf(
+		"WITH affected AS (%s) SELECT to_jsonb(affected), COUNT(*) OVER () FROM affected LIMIT $%d",
+		query, len(args),
+	)
+
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row map[string]interface{}
+		if err := rows.Scan(&row, &result.VisibleRows); err != nil {
+			return err
+		}
+		result.Sample = append(result.Sample, row)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	result.Allowed = true
+	return nil
+}
@@ -0,0 +1,194 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// maxRoleNameLength is PostgreSQL's identifier limit (NAMEDATALEN - 1)
+const maxRoleNameLength = 63
+
+// boolOID is the PostgreSQL type OID of boolean
+const boolOID = 16
+
+// policyValidationError reports a policy rejected before any DDL ran
+type policyValidationError struct {
+	msg string
+}
+
+func (e *policyValidationError) Error() string { return e.msg }
+
+// invalidPolicy returns a policyValidationError with a formatted message
+func invalidPolicy(format string, args ...interface{}) error {
+	return &policyValidationError{msg: fmt.Sprintf(format, args...)}
+}
+
+// isPolicyValidationError reports whether err is a policy validation error
+func isPolicyValidationError(err error) bool {
+	var validationErr *policyValidationError
+	return errors.As(err, &validationErr)
+}
+
+// validateRoleNames checks that role names are usable identifiers
+func validateRoleNames(roles []string) error {
+	for _, role := range roles {
+		if role == "" {
+			return invalidPolicy("role names cannot be empty")
+		}
+		if len(role) > maxRoleNameLength {
+			return invalidPolicy("role name '%s' is longer than %d characters", role, maxRoleNameLength)
+		}
+		if strings.ContainsRune(role, 0) {
+			return invalidPolicy("role name '%s' contains a NUL character", role)
+		}
+	}
+	return nil
+}
+
+// checkExpression is a lexical guard for policy expressions. It rejects
+// semicolons, comments and unbalanced parentheses outside of string
+// literals and quoted identifiers, so an expression cannot end the
+// surrounding USING (...) clause or start another statement.
+func checkExpression(field, expr string) error {
+	depth := 0
+	for i := 0; i < len(expr); i++ {
+		switch ch := expr[i]; {
+		case ch == '\'' && i > 0 && (expr[i-1] == 'E' || expr[i-1] == 'e') && (i == 1 || !isIdentChar(expr[i-2])):
+			// Backslash escapes would end the string where this scan does not
+			return invalidPolicy("%s cannot contain escape strings (E'...')", field)
+		case ch == '\'' || ch == '"':
+			// Skip to the closing quote, doubled quotes are escapes
+			end := i + 1
+			for ; end < len(expr); end++ {
+				if expr[end] == ch {
+					if end+1 < len(expr) && expr[end+1] == ch {
+						end++
+						continue
+					}
+					break
+				}
+			}
+			if end >= len(expr) {
+				return invalidPolicy("%s has an unterminated quoted string", field)
+			}
+			i = end
+		case ch == '$' && (i == 0 || !isIdentChar(expr[i-1])):
+			// Dollar-quoted strings could hide anything, so they are not allowed
+			if i+1 < len(expr) && (expr[i+1] == '$' || isIdentStart(expr[i+1])) {
+				end := i + 1
+				for end < len(expr) && expr[end] != '$' && isIdentChar(expr[end]) {
+					end++
+				}
+				if end < len(expr) && expr[end] == '$' {
+					return invalidPolicy("%s cannot contain dollar-quoted strings", field)
+				}
+			}
+			if i+1 < len(expr) && expr[i+1] >= '0' && expr[i+1] <= '9' {
+				return invalidPolicy("%s cannot contain query parameters", field)
+			}
+		case ch == ';':
+			return invalidPolicy("%s cannot contain semicolons", field)
+		case ch == '-' && i+1 < len(expr) && expr[i+1] == '-',
+			ch == '/' && i+1 < len(expr) && expr[i+1] == '*':
+			return invalidPolicy("%s cannot contain comments", field)
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+			if depth < 0 {
+				return invalidPolicy("%s has unbalanced parentheses", field)
+			}
+		}
+	}
+
+	if depth != 0 {
+		return invalidPolicy("%s has unbalanced parentheses", field)
+	}
+	return nil
+}
+
+// validatePolicyInDatabase checks a policy against the database before any
+// DDL runs: every role must exist in pg_roles and every expression must be
+// a single boolean expression over the table. Expressions are only parsed
+// and analyzed by preparing a SELECT, never executed.
+func validatePolicyInDatabase(ctx context.Context, tx pgx.Tx, policy RLSPolicy) error {
+	var roles []string
+	for _, role := range policy.Roles {
+		if role != "public" {
+			roles = append(roles, role)
+		}
+	}
+
+	if len(roles) > 0 {
+		var missing []string
+		err := tx.QueryRow(ctx, `
+			SELECT COALESCE(array_agg(r), '{}')
+			FROM unnest($1::text[]) AS r
+			WHERE NOT EXISTS (SELECT FROM pg_roles WHERE rolname = r)
+		`, roles).Scan(&missing)
+		if err != nil {
+			return fmt.Errorf("failed to check roles: %w", err)
+		}
+		if len(missing) > 0 {
+			return invalidPolicy("unknown roles: %s", strings.Join(missing, ", "))
+		}
+	}
+
+	expressions := []struct {
+		field string
+		expr  string
+	}{
+		{"definition", policy.Definition},
+		{"check_definition", policy.CheckDefinition},
+	}
+	for _, e := range expressions {
+		if e.expr == "" {
+			continue
+		}
+		if err := checkExpression(e.field, e.expr); err != nil {
+			return err
+		}
+
+		probe := fmt.Sprintf("SELECT (%s) FROM %s", e.expr, pgx.Identifier{policy.TableName}.Sanitize())
+		sd, err := tx.Conn().PgConn().Prepare(ctx, "", probe, nil)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", e.field, &ddlError{statement: probe, err: err})
+		}
+		if len(sd.ParamOIDs) > 0 {
+			return invalidPolicy("%s cannot contain query parameters", e.field)
+		}
+		if len(sd.Fields) != 1 || sd.Fields[0].DataTypeOID != boolOID {
+			return invalidPolicy("%s must be a boolean expression", e.field)
+		}
+	}
+
+	return nil
+}
+
+// quoteRoles quotes role names as identifiers for use in a TO clause,
+// keeping PUBLIC as the keyword
+func quoteRoles(roles []string) string {
+	quoted := make([]string, len(roles))
+	for i, role := range roles {
+		if role == "public" {
+			quoted[i] = "PUBLIC"
+		} else {
+			quoted[i] = pgx.Identifier{role}.Sanitize()
+		}
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// isIdentStart reports whether ch can start an unquoted identifier
+func isIdentStart(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch >= 0x80
+}
+
+// isIdentChar reports whether ch can appear in an unquoted identifier
+func isIdentChar(ch byte) bool {
+	return isIdentStart(ch) || (ch >= '0' && ch <= '9')
+}
@@ -0,0 +1,245 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackson/supabase-go/db"
+	"github.com/jackson/supabase-go/middleware"
+)
+
+// RLSPolicyVersion represents a stored revision of a RLS policy
+type RLSPolicyVersion struct {
+	PolicyID        string    `json:"policy_id"`
+	Version         int       `json:"version"`
+	Operation       string    `json:"operation"`
+	Name            string    `json:"name"`
+	TableName       string    `json:"table_name"`
+	Action          string    `json:"action"`
+	Roles           []string  `json:"roles"`
+	Definition      string    `json:"definition"`
+	CheckDefinition string    `json:"check_definition"`
+	Permissive      bool      `json:"permissive"`
+	Description     string    `json:"description"`
+	ChangedBy       string    `json:"changed_by"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// rlsPolicyVersionColumns is the column list used when reading rls_policy_versions rows
+const rlsPolicyVersionColumns = `
+	policy_id, version, operation, name, table_name, action, roles, definition,
+	check_definition, permissive, COALESCE(description, ''), COALESCE(changed_by, ''),
+	created_at
+`
+
+// GetRLSPolicyHistory returns every revision of a policy, newest first
+func GetRLSPolicyHistory(database *db.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := context.Background()
+		policyID := c.Params("id")
+
+		query := `
+			SELECT ` + rlsPolicyVersionColumns + `
+			FROM rls_policy_versions
+			WHERE policy_id = $1
+			ORDER BY version DESC
+		`
+
+		rows, err := database.Query(ctx, query, policyID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to query policy history: %v", err),
+			})
+		}
+		defer rows.Close()
+
+		versions := []RLSPolicyVersion{}
+		for rows.Next() {
+			var version RLSPolicyVersion
+			if err := scanRLSPolicyVersion(rows, &version); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": fmt.Sprintf("Failed to scan policy version: %v", err),
+				})
+			}
+			versions = append(versions, version)
+		}
+
+		if len(versions) == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": fmt.Sprintf("No history found for policy with ID %s", policyID),
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"versions": versions,
+		})
+	}
+}
+
+// RollbackRLSPolicy restores a policy to the state of an earlier revision.
+// A deleted policy is re-created under its original ID.
+func RollbackRLSPolicy(database *db.DB, rlsEngine *middleware.RLSEngine) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := context.Background()
+		policyID := c.Params("id")
+
+		versionNumber, err := strconv.Atoi(c.Params("version"))
+		if err != nil || versionNumber < 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Version must be a positive integer",
+			})
+		}
+
+		query := `
+			SELECT ` + rlsPolicyVersionColumns + `
+			FROM rls_policy_versions
+			WHERE policy_id = $1 AND version = $2
+		`
+
+		var version RLSPolicyVersion
+		err = scanRLSPolicyVersion(database.QueryRow(ctx, query, policyID, versionNumber), &version)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": fmt.Sprintf("Version %d of policy with ID %s not found", versionNumber, policyID),
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to get policy version: %v", err),
+			})
+		}
+
+		permissive := version.Permissive
+		req := RLSPolicyRequest{
+			Name:            version.Name,
+			TableName:       version.TableName,
+			Action:          version.Action,
+			Roles:           version.Roles,
+			Definition:      version.Definition,
+			CheckDefinition: version.CheckDefinition,
+			Permissive:      &permissive,
+			Description:     version.Description,
+		}
+
+		// Check if the name is still free for the table
+		policyExists, err := policyExists(ctx, database, req.Name, req.TableName, policyID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to check policy existence: %v", err),
+			})
+		}
+		if policyExists {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": fmt.Sprintf("Policy with name '%s' already exists for table '%s'", req.Name, req.TableName),
+			})
+		}
+
+		// The policy may have been deleted since this version was recorded
+		var current RLSPolicy
+		err = getPolicy(ctx, database, policyID, &current)
+		deleted := err == pgx.ErrNoRows
+		if err != nil && !deleted {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to get policy: %v", err),
+			})
+		}
+
+		tx, err := database.Begin(ctx)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to begin transaction: %v", err),
+			})
+		}
+		defer tx.Rollback(ctx)
+
+		userID, _ := c.Locals("userId").(string)
+		change := policyChange{Operation: "rollback", ChangedBy: userID}
+
+		var policy RLSPolicy
+		if deleted {
+			req.ID = policyID
+			policy, err = createPolicy(ctx, tx, req, permissive, change)
+		} else {
+			policy, err = updatePolicy(ctx, tx, current, req, permissive, change)
+		}
+		if err != nil {
+			return c.Status(policyErrorStatus(err)).JSON(policyErrorResponse("Failed to roll back policy", err))
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to commit policy: %v", err),
+			})
+		}
+		rlsEngine.Invalidate(current.TableName)
+		rlsEngine.Invalidate(policy.TableName)
+
+		return c.JSON(policy)
+	}
+}
+
+// recordPolicyVersion stores the given policy state as the next revision
+func recordPolicyVersion(ctx context.Context, tx pgx.Tx, policy RLSPolicy, change policyChange) error {
+	query := `
+		INSERT INTO rls_policy_versions (
+			policy_id, version, operation, name, table_name, action, roles,
+			definition, check_definition, permissive, description, changed_by
+		)
+		SELECT
+			$1, COALESCE(MAX(version), 0) + 1, $2, $3, $4, $5, $6,
+			$7, $8, $9, $10, NULLIF($11, '')
+		FROM rls_policy_versions
+		WHERE policy_id = $1
+	`
+
+	_, err := tx.Exec(
+		ctx,
+		query,
+		policy.ID,
+		change.Operation,
+		policy.Name,
+		policy.TableName,
+		policy.Action,
+		rolesArrayToJson(policy.Roles),
+		policy.Definition,
+		policy.CheckDefinition,
+		policy.Permissive,
+		policy.Description,
+		change.ChangedBy,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record policy version: %w", err)
+	}
+
+	return nil
+}
+
+// scanRLSPolicyVersion scans a row selected with rlsPolicyVersionColumns into version
+func scanRLSPolicyVersion(row pgx.Row, version *RLSPolicyVersion) error {
+	var rolesJson string
+	err := row.Scan(
+		&version.PolicyID,
+		&version.Version,
+		&version.Operation,
+		&version.Name,
+		&version.TableName,
+		&version.Action,
+		&rolesJson,
+		&version.Definition,
+		&version.CheckDefinition,
+		&version.Permissive,
+		&version.Description,
+		&version.ChangedBy,
+		&version.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	version.Roles = parseRoles(rolesJson)
+	return nil
+}
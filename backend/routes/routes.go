@@ -2,14 +2,37 @@ package routes
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackson/supabase-go/config"
 	"github.com/jackson/supabase-go/db"
+	"github.com/jackson/supabase-go/middleware"
 )
 
+// Permissions are the role requirements of the API routes. The first entry
+// matching a request applies; routes without an entry are open to any
+// authenticated caller.
+var Permissions = []middleware.RoutePermission{
+	{Method: fiber.MethodGet, Path: "/api/schema", Roles: []string{middleware.AdminRole, middleware.AnalystRole}},
+	{Path: "/api/query", Roles: []string{middleware.AdminRole}, ReadOnlyRoles: []string{middleware.AnalystRole}},
+	{Method: fiber.MethodGet, Path: "/api/rls/*", Roles: []string{middleware.AdminRole, middleware.AnalystRole}},
+	{Method: fiber.MethodPost, Path: "/api/rls/simulate", Roles: []string{middleware.AdminRole, middleware.AnalystRole}},
+	{Path: "/api/rls/*", Roles: []string{middleware.AdminRole}},
+	{Path: "/api/admin/*", Roles: []string{middleware.AdminRole}},
+}
+
 // Setup configures all API routes
-func Setup(app *fiber.App, database *db.DB) {
+func Setup(app *fiber.App, database *db.DB, cfg *config.Config) {
 	// API prefix
 	api := app.Group("/api")
 
+	// Roles from credentials and user_roles, resolved on first use
+	api.Use(middleware.LoadUserRoles(database))
+
+	// Admins acting as another user, audited in audit_logs
+	api.Use(middleware.Impersonate(database))
+
+	// Role requirements of the admin surfaces
+	api.Use(middleware.Authorize(Permissions))
+
 	// Health check endpoint
 	api.Get("/health", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
@@ -18,19 +41,33 @@ func Setup(app *fiber.App, database *db.DB) {
 		})
 	})
 
+	// Current user and session
+	auth := api.Group("/auth")
+	auth.Get("/user", GetAuthUser())
+	auth.Get("/session", GetAuthSession())
+
 	// Database schema endpoint
 	api.Get("/schema", GetDatabaseSchema(database))
 
+	// Policy engine over rls_policies, shared by table and RLS handlers
+	rlsEngine := middleware.NewRLSEngine(database)
+
+	// Organization scoping of the TENANT_TABLES
+	tenants := middleware.NewTenantScope(cfg.Tenant)
+
 	// Table operations
 	tables := api.Group("/tables")
 	tables.Get("/", GetAllTables(database))
 	tables.Get("/:table", GetTable(database))
 	tables.Get("/:table/columns", GetTableColumns(database))
-	tables.Get("/:table/rows", GetTableRows(database))
-	tables.Post("/:table", CreateTableRow(database))
-	tables.Get("/:table/rows/:id", GetTableRowById(database))
-	tables.Patch("/:table/rows/:id", UpdateTableRow(database))
-	tables.Delete("/:table/rows/:id", DeleteTableRow(database))
+	tables.Get("/:table/rows", GetTableRows(database, rlsEngine, tenants))
+	tables.Post("/:table/rows/search", SearchTableRows(database, rlsEngine, tenants))
+	tables.Patch("/:table/rows", UpdateTableRows(database, rlsEngine, tenants))
+	tables.Delete("/:table/rows", DeleteTableRows(database, rlsEngine, tenants))
+	tables.Post("/:table", CreateTableRow(database, rlsEngine, tenants))
+	tables.Get("/:table/rows/:id", GetTableRowById(database, rlsEngine, tenants))
+	tables.Patch("/:table/rows/:id", UpdateTableRow(database, rlsEngine, tenants))
+	tables.Delete("/:table/rows/:id", DeleteTableRow(database, rlsEngine, tenants))
 
 	// Query operations
 	api.Post("/query", ExecuteQuery(database))
@@ -38,8 +75,30 @@ func Setup(app *fiber.App, database *db.DB) {
 	// Row Level Security policy management
 	rls := api.Group("/rls")
 	rls.Get("/policies", GetRLSPolicies(database))
-	rls.Post("/policies", CreateRLSPolicy(database))
+	rls.Post("/policies", CreateRLSPolicy(database, rlsEngine))
 	rls.Get("/policies/:id", GetRLSPolicy(database))
-	rls.Patch("/policies/:id", UpdateRLSPolicy(database))
-	rls.Delete("/policies/:id", DeleteRLSPolicy(database))
+	rls.Patch("/policies/:id", UpdateRLSPolicy(database, rlsEngine))
+	rls.Delete("/policies/:id", DeleteRLSPolicy(database, rlsEngine))
+	rls.Get("/policies/:id/history", GetRLSPolicyHistory(database))
+	rls.Post("/policies/:id/rollback/:version", RollbackRLSPolicy(database, rlsEngine))
+	rls.Get("/drift", GetRLSDrift(database))
+	rls.Post("/reconcile", ReconcileRLSPolicies(database, rlsEngine))
+	rls.Post("/simulate", SimulateRLSPolicy(database))
+	rls.Get("/export", ExportRLSPolicies(database))
+	rls.Post("/import", ImportRLSPolicies(database, rlsEngine))
+
+	// API key and user role management, admin only. RequireRole repeats the
+	// Permissions entry as a second guard on role grants.
+	admin := api.Group("/admin", middleware.RequireRole(middleware.AdminRole))
+	admin.Get("/keys", GetAPIKeys(database))
+	admin.Post("/keys", CreateAPIKey(database))
+	admin.Get("/keys/:id", GetAPIKey(database))
+	admin.Patch("/keys/:id", UpdateAPIKey(database))
+	admin.Delete("/keys/:id", RevokeAPIKeyHandler(database))
+	admin.Post("/keys/:id/rotate", RotateAPIKeyHandler(database))
+	admin.Get("/users/:id/roles", GetUserRoles(database))
+	admin.Post("/users/:id/roles", GrantUserRole(database))
+	admin.Delete("/users/:id/roles/:role", RevokeUserRole(database))
+
+	middleware.LogRoutePermissions(app, Permissions)
 }
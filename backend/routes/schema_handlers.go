@@ -30,6 +30,7 @@ type IndexInfo struct {
 
 // ForeignKey represents a foreign key relationship
 type ForeignKey struct {
+	Table          string `json:"-"`
 	Name           string `json:"name"`
 	ColumnName     string `json:"column_name"`
 	RefTable       string `json:"reference_table"`
@@ -142,10 +143,11 @@ func getTableIndexes(ctx context.Context, database *db.DB, tableName string) ([]
 	return indexes, nil
 }
 
-// Helper function to get foreign keys for a table
-func getTableForeignKeys(ctx context.Context, database *db.DB, tableName string) ([]ForeignKey, error) {
-	query := `
+// foreignKeysQuery lists foreign key columns with the table and column
+// they reference
+const foreignKeysQuery = `
 		SELECT
+			tc.table_name,
 			tc.constraint_name,
 			kcu.column_name,
 			ccu.table_name AS reference_table,
@@ -160,10 +162,21 @@ func getTableForeignKeys(ctx context.Context, database *db.DB, tableName string)
 				AND ccu.table_schema = tc.table_schema
 		WHERE
 			tc.constraint_type = 'FOREIGN KEY'
-			AND tc.table_name = $1;
 	`
-	
-	rows, err := database.Query(ctx, query, tableName)
+
+// Helper function to get foreign keys for a table
+func getTableForeignKeys(ctx context.Context, database *db.DB, tableName string) ([]ForeignKey, error) {
+	return queryForeignKeys(ctx, database, foreignKeysQuery+" AND tc.table_name = $1", tableName)
+}
+
+// getForeignKeys returns the foreign keys of every table
+func getForeignKeys(ctx context.Context, database *db.DB) ([]ForeignKey, error) {
+	return queryForeignKeys(ctx, database, foreignKeysQuery)
+}
+
+// queryForeignKeys runs a foreignKeysQuery and scans its rows
+func queryForeignKeys(ctx context.Context, database *db.DB, query string, args ...interface{}) ([]ForeignKey, error) {
+	rows, err := database.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -173,7 +186,7 @@ func getTableForeignKeys(ctx context.Context, database *db.DB, tableName string)
 	for rows.Next() {
 		var fk ForeignKey
 		
-		if err := rows.Scan(&fk.Name, &fk.ColumnName, &fk.RefTable, &fk.RefColumnName); err != nil {
+		if err := rows.Scan(&fk.Table, &fk.Name, &fk.ColumnName, &fk.RefTable, &fk.RefColumnName); err != nil {
 			return nil, err
 		}
 		
@@ -3,14 +3,16 @@ package routes
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
+	"net/url"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackson/supabase-go/db"
 	"github.com/jackson/supabase-go/middleware"
+	"github.com/jackson/supabase-go/pkg/querybuilder"
 )
 
 // GetAllTables returns a list of all tables in the database
@@ -67,9 +69,11 @@ func GetTable(database *db.DB) fiber.Handler {
 			})
 		}
 
-		// Get row count
+		// Get row count as the caller so RLS policies apply
 		var count int
-		err = database.QueryRow(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", pgx.Identifier{tableName}.Sanitize())).Scan(&count)
+		err = database.WithRequestClaims(ctx, requestClaims(c), func(tx pgx.Tx) error {
+			return tx.QueryRow(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", pgx.Identifier{tableName}.Sanitize())).Scan(&count)
+		})
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": fmt.Sprintf("Failed to get row count: %v", err),
@@ -105,102 +109,154 @@ func GetTableColumns(database *db.DB) fiber.Handler {
 }
 
 // GetTableRows returns rows from a table with filtering and pagination
-func GetTableRows(database *db.DB) fiber.Handler {
+func GetTableRows(database *db.DB, rlsEngine *middleware.RLSEngine, tenants *middleware.TenantScope) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		tableName := c.Params("table")
 		ctx := context.Background()
 
 		// Check RLS policies for the current user
-		user := c.Locals("user")
-		allowed, err := middleware.CheckRLS(database, user, tableName, "select")
-		if err != nil || !allowed {
+		decision, err := rlsEngine.CheckRLS(ctx, tableName, "select", requestClaims(c).Role)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to evaluate row-level security policies: %v", err),
+			})
+		}
+		if !decision.Allowed {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"error": "Access denied by row-level security policy",
 			})
 		}
 
-		// Pagination parameters
-		page, _ := strconv.Atoi(c.Query("page", "1"))
-		pageSize, _ := strconv.Atoi(c.Query("page_size", "10"))
-		if page < 1 {
-			page = 1
-		}
-		if pageSize < 1 || pageSize > 100 {
-			pageSize = 10
+		// Filters, sorting and pagination
+		query, err := parseQuery(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid query: %v", err),
+			})
 		}
-		offset := (page - 1) * pageSize
 
-		// Build the query with filters
-		queryFilters := buildQueryFilters(c)
-		
-		// Main query
-		query := fmt.Sprintf("SELECT * FROM %s", pgx.Identifier{tableName}.Sanitize())
-		if len(queryFilters.wheres) > 0 {
-			query += " WHERE " + strings.Join(queryFilters.wheres, " AND ")
-		}
-		
-		// Order by
-		orderBy := c.Query("order_by")
-		orderDir := c.Query("order_dir", "asc")
-		if orderBy != "" {
-			query += fmt.Sprintf(" ORDER BY %s %s", pgx.Identifier{orderBy}.Sanitize(), orderDir)
-		}
-		
-		// Add pagination
-		query += fmt.Sprintf(" LIMIT %d OFFSET %d", pageSize, offset)
+		return selectTableRows(c, database, rlsEngine, tenants, tableName, query)
+	}
+}
 
-		// Execute the query
-		rows, err := database.Query(ctx, query, queryFilters.params...)
+// SearchTableRows returns rows from a table like GetTableRows, reading the
+// filters from a JSON body. Filters can nest and, or and not conditions.
+func SearchTableRows(database *db.DB, rlsEngine *middleware.RLSEngine, tenants *middleware.TenantScope) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tableName := c.Params("table")
+		ctx := context.Background()
+
+		// Check RLS policies for the current user
+		decision, err := rlsEngine.CheckRLS(ctx, tableName, "select", requestClaims(c).Role)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": fmt.Sprintf("Failed to query table: %v", err),
+				"error": fmt.Sprintf("Failed to evaluate row-level security policies: %v", err),
+			})
+		}
+		if !decision.Allowed {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Access denied by row-level security policy",
 			})
 		}
-		defer rows.Close()
 
-		// Convert rows to JSON
-		data, err := pgxRowsToJSON(rows)
+		query, err := querybuilder.ParseJSON(c.Body())
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": fmt.Sprintf("Failed to process results: %v", err),
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid query: %v", err),
 			})
 		}
 
-		// Count total rows (for pagination)
-		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s", pgx.Identifier{tableName}.Sanitize())
-		if len(queryFilters.wheres) > 0 {
-			countQuery += " WHERE " + strings.Join(queryFilters.wheres, " AND ")
+		return selectTableRows(c, database, rlsEngine, tenants, tableName, query)
+	}
+}
+
+// selectTableRows runs a parsed query on a table as the caller and writes
+// a page of rows with the total count
+func selectTableRows(c *fiber.Ctx, database *db.DB, rlsEngine *middleware.RLSEngine, tenants *middleware.TenantScope, tableName string, query *querybuilder.Query) error {
+	ctx := context.Background()
+
+	var args querybuilder.Args
+	conditions, err := filterConditions(c, tenants, tableName, query, &args)
+	if err != nil {
+		return tenantError(c, err)
+	}
+
+	// Inner embeds filter the rows, so they count towards the total. The
+	// values of the embedded columns come last and are not passed to the
+	// count query.
+	embeds := newEmbedder(ctx, c, database, rlsEngine, tenants, &args)
+	inner, err := embeds.conditions(tableName, query)
+	if err != nil {
+		return selectError(c, err)
+	}
+	conditions = append(conditions, inner...)
+	countArgs := len(args)
+
+	columns, err := embeds.columns(tableName, query)
+	if err != nil {
+		return selectError(c, err)
+	}
+
+	from := pgx.Identifier{tableName}.Sanitize()
+	if len(conditions) > 0 {
+		from += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// Main query and total count (for pagination)
+	selectQuery := strings.TrimSpace(fmt.Sprintf("SELECT %s FROM %s %s %s", columns, from, query.OrderBy(tableName), query.Pagination()))
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s", from)
+
+	// Run both queries as the caller so RLS policies filter the rows
+	var data []map[string]interface{}
+	var total int
+	err = database.WithRequestClaims(ctx, requestClaims(c), func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, selectQuery, args...)
+		if err != nil {
+			return err
 		}
-		
-		var total int
-		err = database.QueryRow(ctx, countQuery, queryFilters.params...).Scan(&total)
+
+		// Convert rows to JSON
+		data, err = pgxRowsToJSON(rows)
+		rows.Close()
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": fmt.Sprintf("Failed to get total count: %v", err),
-			})
+			return fmt.Errorf("failed to process results: %w", err)
 		}
 
-		return c.JSON(fiber.Map{
-			"data":       data,
-			"page":       page,
-			"page_size":  pageSize,
-			"total":      total,
-			"total_pages": (total + pageSize - 1) / pageSize,
+		if err := tx.QueryRow(ctx, countQuery, args[:countArgs]...).Scan(&total); err != nil {
+			return fmt.Errorf("failed to get total count: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to query table: %v", err),
 		})
 	}
+
+	return c.JSON(fiber.Map{
+		"data":       data,
+		"page":       query.Page(),
+		"page_size":  query.Limit,
+		"total":      total,
+		"total_pages": (total + query.Limit - 1) / query.Limit,
+	})
 }
 
 // GetTableRowById returns a single row by its ID
-func GetTableRowById(database *db.DB) fiber.Handler {
+func GetTableRowById(database *db.DB, rlsEngine *middleware.RLSEngine, tenants *middleware.TenantScope) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		tableName := c.Params("table")
 		idParam := c.Params("id")
 		ctx := context.Background()
 
 		// Check RLS policies for the current user
-		user := c.Locals("user")
-		allowed, err := middleware.CheckRLS(database, user, tableName, "select")
-		if err != nil || !allowed {
+		decision, err := rlsEngine.CheckRLS(ctx, tableName, "select", requestClaims(c).Role)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to evaluate row-level security policies: %v", err),
+			})
+		}
+		if !decision.Allowed {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"error": "Access denied by row-level security policy",
 			})
@@ -214,13 +270,44 @@ func GetTableRowById(database *db.DB) fiber.Handler {
 			})
 		}
 
+		// Project the columns and embedded resources named by select
+		params, err := parseQuery(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid query: %v", err),
+			})
+		}
+		var args querybuilder.Args
+		embeds := newEmbedder(ctx, c, database, rlsEngine, tenants, &args)
+		columns, err := embeds.columns(tableName, params)
+		if err != nil {
+			return selectError(c, err)
+		}
+		inner, err := embeds.conditions(tableName, params)
+		if err != nil {
+			return selectError(c, err)
+		}
+
 		// Query the row
-		query := fmt.Sprintf("SELECT * FROM %s WHERE %s = $1", 
+		query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = %s", 
+			columns,
 			pgx.Identifier{tableName}.Sanitize(), 
-			pgx.Identifier{primaryKeyColumn}.Sanitize())
+			pgx.Identifier{primaryKeyColumn}.Sanitize(),
+			args.Add(idParam))
+
+		// Scope tenant tables to the active organization
+		condition, err := tenantCondition(c, tenants, tableName, &args)
+		if err != nil {
+			return tenantError(c, err)
+		}
+		if condition != "" {
+			query += " AND " + condition
+		}
+		for _, condition := range inner {
+			query += " AND " + condition
+		}
 		
-		row := database.QueryRow(ctx, query, idParam)
-		result, err := pgxRowToJSON(row)
+		result, err := queryRowAsCaller(ctx, c, database, query, args...)
 		if err != nil {
 			if err == pgx.ErrNoRows {
 				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -237,15 +324,19 @@ func GetTableRowById(database *db.DB) fiber.Handler {
 }
 
 // CreateTableRow creates a new row in the specified table
-func CreateTableRow(database *db.DB) fiber.Handler {
+func CreateTableRow(database *db.DB, rlsEngine *middleware.RLSEngine, tenants *middleware.TenantScope) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		tableName := c.Params("table")
 		ctx := context.Background()
 
 		// Check RLS policies for the current user
-		user := c.Locals("user")
-		allowed, err := middleware.CheckRLS(database, user, tableName, "insert")
-		if err != nil || !allowed {
+		decision, err := rlsEngine.CheckRLS(ctx, tableName, "insert", requestClaims(c).Role)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to evaluate row-level security policies: %v", err),
+			})
+		}
+		if !decision.Allowed {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"error": "Access denied by row-level security policy",
 			})
@@ -259,6 +350,23 @@ func CreateTableRow(database *db.DB) fiber.Handler {
 			})
 		}
 
+		// Stamp rows of tenant tables with the active organization
+		if err := stampTenant(c, tenants, tableName, data); err != nil {
+			return tenantError(c, err)
+		}
+
+		// select picks the columns returned for the new row
+		params, err := parseQuery(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid query: %v", err),
+			})
+		}
+		returning, err := selectColumns(ctx, database, tableName, params)
+		if err != nil {
+			return selectError(c, err)
+		}
+
 		// Get table columns
 		columns, err := database.GetTableColumns(ctx, tableName)
 		if err != nil {
@@ -290,15 +398,15 @@ func CreateTableRow(database *db.DB) fiber.Handler {
 
 		// Create the INSERT query
 		query := fmt.Sprintf(
-			"INSERT INTO %s (%s) VALUES (%s) RETURNING *",
+			"INSERT INTO %s (%s) VALUES (%s) RETURNING %s",
 			pgx.Identifier{tableName}.Sanitize(),
 			strings.Join(columnNames, ", "),
 			strings.Join(placeholders, ", "),
+			returning,
 		)
 
 		// Execute the query
-		row := database.QueryRow(ctx, query, values...)
-		result, err := pgxRowToJSON(row)
+		result, err := queryRowAsCaller(ctx, c, database, query, values...)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": fmt.Sprintf("Failed to insert row: %v", err),
@@ -310,16 +418,20 @@ func CreateTableRow(database *db.DB) fiber.Handler {
 }
 
 // UpdateTableRow updates an existing row in the specified table
-func UpdateTableRow(database *db.DB) fiber.Handler {
+func UpdateTableRow(database *db.DB, rlsEngine *middleware.RLSEngine, tenants *middleware.TenantScope) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		tableName := c.Params("table")
 		idParam := c.Params("id")
 		ctx := context.Background()
 
 		// Check RLS policies for the current user
-		user := c.Locals("user")
-		allowed, err := middleware.CheckRLS(database, user, tableName, "update")
-		if err != nil || !allowed {
+		decision, err := rlsEngine.CheckRLS(ctx, tableName, "update", requestClaims(c).Role)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to evaluate row-level security policies: %v", err),
+			})
+		}
+		if !decision.Allowed {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"error": "Access denied by row-level security policy",
 			})
@@ -333,6 +445,21 @@ func UpdateTableRow(database *db.DB) fiber.Handler {
 			})
 		}
 
+		// Filters narrow the update further
+		filters, err := parseQuery(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid query: %v", err),
+			})
+		}
+
+		// Rows cannot be moved to another organization
+		if _, exists := data[tenants.Column()]; exists {
+			if err := stampTenant(c, tenants, tableName, data); err != nil {
+				return tenantError(c, err)
+			}
+		}
+
 		// Get primary key column
 		primaryKeyColumn, err := getPrimaryKeyColumn(ctx, database, tableName)
 		if err != nil {
@@ -343,14 +470,11 @@ func UpdateTableRow(database *db.DB) fiber.Handler {
 
 		// Build update query
 		setStatements := []string{}
-		values := []interface{}{}
-		paramCounter := 1
+		var values querybuilder.Args
 
 		for column, value := range data {
-			setStatements = append(setStatements, fmt.Sprintf("%s = $%d", 
-				pgx.Identifier{column}.Sanitize(), paramCounter))
-			values = append(values, value)
-			paramCounter++
+			setStatements = append(setStatements, fmt.Sprintf("%s = %s", 
+				pgx.Identifier{column}.Sanitize(), values.Add(value)))
 		}
 
 		if len(setStatements) == 0 {
@@ -359,21 +483,31 @@ func UpdateTableRow(database *db.DB) fiber.Handler {
 			})
 		}
 
-		// Add the primary key value for the WHERE clause
-		values = append(values, idParam)
+		// Add the primary key value for the WHERE clause, and only update
+		// rows of the active organization that match the filters
+		where := fmt.Sprintf("%s = %s", pgx.Identifier{primaryKeyColumn}.Sanitize(), values.Add(idParam))
+		conditions, err := filterConditions(c, tenants, tableName, filters, &values)
+		if err != nil {
+			return tenantError(c, err)
+		}
+		where = strings.Join(append([]string{where}, conditions...), " AND ")
+
+		returning, err := selectColumns(ctx, database, tableName, filters)
+		if err != nil {
+			return selectError(c, err)
+		}
 
 		// Create the UPDATE query
 		query := fmt.Sprintf(
-			"UPDATE %s SET %s WHERE %s = $%d RETURNING *",
+			"UPDATE %s SET %s WHERE %s RETURNING %s",
 			pgx.Identifier{tableName}.Sanitize(),
 			strings.Join(setStatements, ", "),
-			pgx.Identifier{primaryKeyColumn}.Sanitize(),
-			paramCounter,
+			where,
+			returning,
 		)
 
 		// Execute the query
-		row := database.QueryRow(ctx, query, values...)
-		result, err := pgxRowToJSON(row)
+		result, err := queryRowAsCaller(ctx, c, database, query, values...)
 		if err != nil {
 			if err == pgx.ErrNoRows {
 				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -390,16 +524,20 @@ func UpdateTableRow(database *db.DB) fiber.Handler {
 }
 
 // DeleteTableRow deletes a row from the specified table
-func DeleteTableRow(database *db.DB) fiber.Handler {
+func DeleteTableRow(database *db.DB, rlsEngine *middleware.RLSEngine, tenants *middleware.TenantScope) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		tableName := c.Params("table")
 		idParam := c.Params("id")
 		ctx := context.Background()
 
 		// Check RLS policies for the current user
-		user := c.Locals("user")
-		allowed, err := middleware.CheckRLS(database, user, tableName, "delete")
-		if err != nil || !allowed {
+		decision, err := rlsEngine.CheckRLS(ctx, tableName, "delete", requestClaims(c).Role)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to evaluate row-level security policies: %v", err),
+			})
+		}
+		if !decision.Allowed {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"error": "Access denied by row-level security policy",
 			})
@@ -413,16 +551,38 @@ func DeleteTableRow(database *db.DB) fiber.Handler {
 			})
 		}
 
+		// Filters narrow the delete further
+		filters, err := parseQuery(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid query: %v", err),
+			})
+		}
+
+		// Only delete rows of the active organization that match the filters
+		var args querybuilder.Args
+		where := fmt.Sprintf("%s = %s", pgx.Identifier{primaryKeyColumn}.Sanitize(), args.Add(idParam))
+		conditions, err := filterConditions(c, tenants, tableName, filters, &args)
+		if err != nil {
+			return tenantError(c, err)
+		}
+		where = strings.Join(append([]string{where}, conditions...), " AND ")
+
+		returning, err := selectColumns(ctx, database, tableName, filters)
+		if err != nil {
+			return selectError(c, err)
+		}
+
 		// Create the DELETE query
 		query := fmt.Sprintf(
-			"DELETE FROM %s WHERE %s = $1 RETURNING *",
+			"DELETE FROM %s WHERE %s RETURNING %s",
 			pgx.Identifier{tableName}.Sanitize(),
-			pgx.Identifier{primaryKeyColumn}.Sanitize(),
+			where,
+			returning,
 		)
 
 		// Execute the query
-		row := database.QueryRow(ctx, query, idParam)
-		result, err := pgxRowToJSON(row)
+		result, err := queryRowAsCaller(ctx, c, database, query, args...)
 		if err != nil {
 			if err == pgx.ErrNoRows {
 				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -438,6 +598,160 @@ func DeleteTableRow(database *db.DB) fiber.Handler {
 	}
 }
 
+// UpdateTableRows updates every row of a table matching the request's
+// filters. At least one filter is required.
+func UpdateTableRows(database *db.DB, rlsEngine *middleware.RLSEngine, tenants *middleware.TenantScope) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tableName := c.Params("table")
+		ctx := context.Background()
+
+		// Check RLS policies for the current user
+		decision, err := rlsEngine.CheckRLS(ctx, tableName, "update", requestClaims(c).Role)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to evaluate row-level security policies: %v", err),
+			})
+		}
+		if !decision.Allowed {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Access denied by row-level security policy",
+			})
+		}
+
+		filters, err := parseQuery(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid query: %v", err),
+			})
+		}
+		if !filters.HasConditions() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "At least one filter is required to update rows",
+			})
+		}
+
+		// Parse request body
+		var data map[string]interface{}
+		if err := c.BodyParser(&data); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid request body: %v", err),
+			})
+		}
+
+		// Rows cannot be moved to another organization
+		if _, exists := data[tenants.Column()]; exists {
+			if err := stampTenant(c, tenants, tableName, data); err != nil {
+				return tenantError(c, err)
+			}
+		}
+
+		// Build update query
+		setStatements := []string{}
+		var values querybuilder.Args
+		for column, value := range data {
+			setStatements = append(setStatements, fmt.Sprintf("%s = %s",
+				pgx.Identifier{column}.Sanitize(), values.Add(value)))
+		}
+		if len(setStatements) == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "No fields provided for update",
+			})
+		}
+
+		conditions, err := filterConditions(c, tenants, tableName, filters, &values)
+		if err != nil {
+			return tenantError(c, err)
+		}
+
+		returning, err := selectColumns(ctx, database, tableName, filters)
+		if err != nil {
+			return selectError(c, err)
+		}
+
+		query := fmt.Sprintf(
+			"UPDATE %s SET %s WHERE %s RETURNING %s",
+			pgx.Identifier{tableName}.Sanitize(),
+			strings.Join(setStatements, ", "),
+			strings.Join(conditions, " AND "),
+			returning,
+		)
+
+		result, err := queryRowsAsCaller(ctx, c, database, query, values...)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to update rows: %v", err),
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"data": result,
+		})
+	}
+}
+
+// DeleteTableRows deletes every row of a table matching the request's
+// filters. At least one filter is required.
+func DeleteTableRows(database *db.DB, rlsEngine *middleware.RLSEngine, tenants *middleware.TenantScope) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tableName := c.Params("table")
+		ctx := context.Background()
+
+		// Check RLS policies for the current user
+		decision, err := rlsEngine.CheckRLS(ctx, tableName, "delete", requestClaims(c).Role)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to evaluate row-level security policies: %v", err),
+			})
+		}
+		if !decision.Allowed {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Access denied by row-level security policy",
+			})
+		}
+
+		filters, err := parseQuery(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid query: %v", err),
+			})
+		}
+		if !filters.HasConditions() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "At least one filter is required to delete rows",
+			})
+		}
+
+		var args querybuilder.Args
+		conditions, err := filterConditions(c, tenants, tableName, filters, &args)
+		if err != nil {
+			return tenantError(c, err)
+		}
+
+		returning, err := selectColumns(ctx, database, tableName, filters)
+		if err != nil {
+			return selectError(c, err)
+		}
+
+		query := fmt.Sprintf(
+			"DELETE FROM %s WHERE %s RETURNING %s",
+			pgx.Identifier{tableName}.Sanitize(),
+			strings.Join(conditions, " AND "),
+			returning,
+		)
+
+		result, err := queryRowsAsCaller(ctx, c, database, query, args...)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to delete rows: %v", err),
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"data": result,
+		})
+	}
+}
+
 // Helper to convert pgx.Rows to JSON array
 func pgxRowsToJSON(rows pgx.Rows) ([]map[string]interface{}, error) {
 	// Get the column info
@@ -466,13 +780,15 @@ func pgxRowsToJSON(rows pgx.Rows) ([]map[string]interface{}, error) {
 	return result, nil
 }
 
-// Helper to convert a single pgx.Row to JSON
-func pgxRowToJSON(row pgx.Row) (map[string]interface{}, error) {
-	// Get the underlying pgx.Rows interface
-	rows := row.(interface{ Rows() pgx.Rows }).Rows()
-	
+// Helper to convert the first row of pgx.Rows to JSON
+func pgxRowToJSON(rows pgx.Rows) (map[string]interface{}, error) {
+	defer rows.Close()
+
 	// Check if there are any rows
 	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
 		return nil, pgx.ErrNoRows
 	}
 	
@@ -496,109 +812,130 @@ func pgxRowToJSON(row pgx.Row) (map[string]interface{}, error) {
 	return result, nil
 }
 
-// QueryFilter holds information for filtering database queries
-type QueryFilter struct {
-	wheres []string
-	params []interface{}
+// queryRowAsCaller runs a single-row query as the request's database role
+// and converts the result to JSON
+func queryRowAsCaller(ctx context.Context, c *fiber.Ctx, database *db.DB, query string, args ...interface{}) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	err := database.WithRequestClaims(ctx, requestClaims(c), func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		result, err = pgxRowToJSON(rows)
+		return err
+	})
+	return result, err
 }
 
-// buildQueryFilters extracts filter parameters from the request
-func buildQueryFilters(c *fiber.Ctx) QueryFilter {
-	filters := QueryFilter{
-		wheres: []string{},
-		params: []interface{}{},
-	}
-	
-	paramCounter := 1
-	
-	// Process all query parameters
-	c.Context().QueryArgs().VisitAll(func(key, val []byte) {
-		k := string(key)
-		v := string(val)
-		
-		// Skip pagination and sorting parameters
-		if k == "page" || k == "page_size" || k == "order_by" || k == "order_dir" {
-			return
+// queryRowsAsCaller runs a query as the request's database role and
+// converts the resulting rows to JSON
+func queryRowsAsCaller(ctx context.Context, c *fiber.Ctx, database *db.DB, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	var result []map[string]interface{}
+	err := database.WithRequestClaims(ctx, requestClaims(c), func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, query, args...)
+		if err != nil {
+			return err
 		}
-		
-		// Handle operators in column names
-		parts := strings.Split(k, ".")
-		if len(parts) == 2 {
-			column := parts[0]
-			operator := parts[1]
-			
-			switch operator {
-			case "eq":
-				filters.wheres = append(filters.wheres, fmt.Sprintf("%s = $%d", 
-					pgx.Identifier{column}.Sanitize(), paramCounter))
-				filters.params = append(filters.params, v)
-				paramCounter++
-				
-			case "neq":
-				filters.wheres = append(filters.wheres, fmt.Sprintf("%s != $%d", 
-					pgx.Identifier{column}.Sanitize(), paramCounter))
-				filters.params = append(filters.params, v)
-				paramCounter++
-				
-			case "gt":
-				filters.wheres = append(filters.wheres, fmt.Sprintf("%s > $%d", 
-					pgx.Identifier{column}.Sanitize(), paramCounter))
-				filters.params = append(filters.params, v)
-				paramCounter++
-				
-			case "gte":
-				filters.wheres = append(filters.wheres, fmt.Sprintf("%s >= $%d", 
-					pgx.Identifier{column}.Sanitize(), paramCounter))
-				filters.params = append(filters.params, v)
-				paramCounter++
-				
-			case "lt":
-				filters.wheres = append(filters.wheres, fmt.Sprintf("%s < $%d", 
-					pgx.Identifier{column}.Sanitize(), paramCounter))
-				filters.params = append(filters.params, v)
-				paramCounter++
-				
-			case "lte":
-				filters.wheres = append(filters.wheres, fmt.Sprintf("%s <= $%d", 
-					pgx.Identifier{column}.Sanitize(), paramCounter))
-				filters.params = append(filters.params, v)
-				paramCounter++
-				
-			case "like":
-				filters.wheres = append(filters.wheres, fmt.Sprintf("%s LIKE $%d", 
-					pgx.Identifier{column}.Sanitize(), paramCounter))
-				filters.params = append(filters.params, "%"+v+"%")
-				paramCounter++
-				
-			case "in":
-				// Parse comma-separated values
-				values := strings.Split(v, ",")
-				placeholders := make([]string, len(values))
-				
-				for i, val := range values {
-					placeholders[i] = fmt.Sprintf("$%d", paramCounter)
-					filters.params = append(filters.params, val)
-					paramCounter++
-				}
-				
-				filters.wheres = append(filters.wheres, fmt.Sprintf("%s IN (%s)", 
-					pgx.Identifier{column}.Sanitize(), 
-					strings.Join(placeholders, ", ")))
-			}
-		} else {
-			// Simple equality filter
-			filters.wheres = append(filters.wheres, fmt.Sprintf("%s = $%d", 
-				pgx.Identifier{k}.Sanitize(), paramCounter))
-			filters.params = append(filters.params, v)
-			paramCounter++
+		defer rows.Close()
+		result, err = pgxRowsToJSON(rows)
+		return err
+	})
+	return result, err
+}
+
+// filterConditions returns the conditions of the request's filters on a
+// table, including the tenant scope, adding their values to args
+func filterConditions(c *fiber.Ctx, tenants *middleware.TenantScope, tableName string, query *querybuilder.Query, args *querybuilder.Args) ([]string, error) {
+	conditions := []string{}
+	if where := query.Where(tableName, args); where != "" {
+		conditions = append(conditions, where)
+	}
+
+	// Scope tenant tables to the active organization
+	condition, err := tenantCondition(c, tenants, tableName, args)
+	if err != nil {
+		return nil, err
+	}
+	if condition != "" {
+		conditions = append(conditions, condition)
+	}
+
+	return conditions, nil
+}
+
+// errUnknownColumn is returned for select lists naming a column the table
+// does not have
+var errUnknownColumn = errors.New("unknown column")
+
+// selectColumns renders the request's select list for a table, the columns
+// of both SELECT and RETURNING clauses. Every named column must exist, and
+// embedded resources are left to reads.
+func selectColumns(ctx context.Context, database *db.DB, tableName string, query *querybuilder.Query) (string, error) {
+	if len(query.Embeds) > 0 {
+		return "", errEmbedOnWrite
+	}
+	if err := checkColumns(ctx, database, tableName, query); err != nil {
+		return "", err
+	}
+	return query.Columns(tableName), nil
+}
+
+// checkColumns checks that the columns named by the query's select list
+// exist in the table
+func checkColumns(ctx context.Context, database *db.DB, tableName string, query *querybuilder.Query) error {
+	names := query.ColumnNames()
+	if len(names) == 0 {
+		return nil
+	}
+
+	columns, err := database.GetTableColumns(ctx, tableName)
+	if err != nil {
+		return fmt.Errorf("failed to get columns: %w", err)
+	}
+
+	known := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		known[column.Name] = true
+	}
+	for _, name := range names {
+		if !known[name] {
+			return fmt.Errorf("%w %q in %q", errUnknownColumn, name, tableName)
 		}
+	}
+	return nil
+}
+
+// selectError writes the response for a select list that cannot be used
+func selectError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errUnknownColumn), errors.Is(err, errUnknownRelationship), errors.Is(err, errEmbedOnWrite):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Invalid select: %v", err),
+		})
+	case errors.Is(err, errEmbedDenied):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": fmt.Sprintf("Access denied by row-level security policy on %v", err),
+		})
+	case errors.Is(err, middleware.ErrNoOrganization):
+		return tenantError(c, err)
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": fmt.Sprintf("Failed to resolve select: %v", err),
 	})
-	
-	return filters
+}
+
+// parseQuery parses the filter, sorting and pagination parameters of the
+// request
+func parseQuery(c *fiber.Ctx) (*querybuilder.Query, error) {
+	values, err := url.ParseQuery(string(c.Request().URI().QueryString()))
+	if err != nil {
+		return nil, err
+	}
+	return querybuilder.Parse(values)
 }
 
 // getPrimaryKeyColumn determines the primary key column for a table
-func getPrimaryKeyColumn(ctx context.Context, database *db.DB, tableName string) (string, error) {
+func getPrimaryKeyColumn(ctx context.Context, database db.Querier, tableName string) (string, error) {
 	query := `
 		SELECT a.attname
 		FROM pg_index i
@@ -0,0 +1,60 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackson/supabase-go/middleware"
+	"github.com/jackson/supabase-go/pkg/querybuilder"
+)
+
+// errForeignOrganization is returned for writes that name another
+// organization in the tenant column
+var errForeignOrganization = errors.New("rows of another organization cannot be written")
+
+// tenantCondition returns the condition limiting a scoped table to the
+// request's organization, adding the organization to args. Tables that are
+// not scoped get an empty condition.
+func tenantCondition(c *fiber.Ctx, tenants *middleware.TenantScope, table string, args *querybuilder.Args) (string, error) {
+	if !tenants.Applies(table) {
+		return "", nil
+	}
+
+	orgID, err := tenants.OrgID(c)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s = %s", pgx.Identifier{tenants.Column()}.Sanitize(), args.Add(orgID)), nil
+}
+
+// stampTenant sets the tenant column of a row written to a scoped table
+func stampTenant(c *fiber.Ctx, tenants *middleware.TenantScope, table string, data map[string]interface{}) error {
+	if !tenants.Applies(table) {
+		return nil
+	}
+
+	orgID, err := tenants.OrgID(c)
+	if err != nil {
+		return err
+	}
+
+	if value, exists := data[tenants.Column()]; exists && value != nil && fmt.Sprint(value) != orgID {
+		return errForeignOrganization
+	}
+	data[tenants.Column()] = orgID
+	return nil
+}
+
+// tenantError writes the response for requests outside their organization
+func tenantError(c *fiber.Ctx, err error) error {
+	message := "An active organization is required"
+	if err == errForeignOrganization {
+		message = "Rows of another organization cannot be written"
+	}
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error": message,
+	})
+}
@@ -0,0 +1,168 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackson/supabase-go/db"
+)
+
+// UserRole represents a row of the user_roles table
+type UserRole struct {
+	Role      string    `json:"role"`
+	Primary   bool      `json:"primary"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserRoleRequest represents a request to grant a role to a user
+type UserRoleRequest struct {
+	Role    string `json:"role"`
+	Primary bool   `json:"primary"`
+}
+
+// GetUserRoles returns the roles granted to a user in user_roles
+func GetUserRoles(database *db.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := context.Background()
+		userID := c.Params("id")
+
+		roles, err := listUserRoles(ctx, database, userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to query user roles: %v", err),
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"user_id": userID,
+			"roles":   roles,
+		})
+	}
+}
+
+// GrantUserRole grants a role to a user. Granting a role the user already
+// has only updates whether it is their primary role.
+func GrantUserRole(database *db.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := context.Background()
+		userID := c.Params("id")
+
+		var req UserRoleRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid request body: %v", err),
+			})
+		}
+		if req.Role == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "role is required",
+			})
+		}
+		if err := validateRoleNames([]string{req.Role}); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		tx, err := database.Begin(ctx)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to begin transaction: %v", err),
+			})
+		}
+		defer tx.Rollback(ctx)
+
+		// A user has at most one primary role
+		if req.Primary {
+			_, err := tx.Exec(ctx, "UPDATE user_roles SET is_primary = FALSE WHERE user_id = $1 AND is_primary AND role <> $2", userID, req.Role)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": fmt.Sprintf("Failed to update primary role: %v", err),
+				})
+			}
+		}
+
+		query := `
+			INSERT INTO user_roles (user_id, role, is_primary)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, role) DO UPDATE SET is_primary = EXCLUDED.is_primary
+		`
+		if _, err := tx.Exec(ctx, query, userID, req.Role, req.Primary); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to grant role: %v", err),
+			})
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to commit role: %v", err),
+			})
+		}
+
+		roles, err := listUserRoles(ctx, database, userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to query user roles: %v", err),
+			})
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"user_id": userID,
+			"roles":   roles,
+		})
+	}
+}
+
+// RevokeUserRole removes a role from a user
+func RevokeUserRole(database *db.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := context.Background()
+		userID := c.Params("id")
+		role := c.Params("role")
+
+		result, err := database.Exec(ctx, "DELETE FROM user_roles WHERE user_id = $1 AND role = $2", userID, role)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to revoke role: %v", err),
+			})
+		}
+		if result.RowsAffected() == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": fmt.Sprintf("User %s does not have role '%s'", userID, role),
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Role revoked successfully",
+		})
+	}
+}
+
+// listUserRoles returns the user_roles rows of a user, primary role first
+func listUserRoles(ctx context.Context, database *db.DB, userID string) ([]UserRole, error) {
+	query := `
+		SELECT role, is_primary, created_at
+		FROM user_roles
+		WHERE user_id = $1
+		ORDER BY is_primary DESC, created_at
+	`
+
+	rows, err := database.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []UserRole{}
+	for rows.Next() {
+		var role UserRole
+		if err := rows.Scan(&role.Role, &role.Primary, &role.CreatedAt); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
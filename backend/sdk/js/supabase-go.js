@@ -247,7 +247,10 @@ class QueryBuilder {
   }
 
   /**
-   * Select specific columns
+   * Select specific columns. Columns can be renamed (`name:title`), cast
+   * (`price::text`) or read from JSON (`data->>city`), and the selection
+   * also applies to the rows returned by insert, update and delete. Reads
+   * can embed related tables, e.g. `id,title,author(name),comments(*)`.
    * @param {string|Array} columns - Columns to select
    * @returns {QueryBuilder} - The query builder instance
    */